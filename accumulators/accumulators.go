@@ -0,0 +1,89 @@
+package accumulators
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// Accumulator computes a single value from all documents in a group
+type Accumulator *bson.M
+
+// Sum returns the sum of the expression for all documents. Non numeric values are ignored
+func Sum(expression interface{}) Accumulator {
+	return Accumulator(&bson.M{
+		"$sum": expression,
+	})
+}
+
+// Count returns the amount of documents
+func Count() Accumulator {
+	return Sum(1)
+}
+
+// Avg returns the average of the expression for all documents. Non numeric values are ignored
+func Avg(expression interface{}) Accumulator {
+	return Accumulator(&bson.M{
+		"$avg": expression,
+	})
+}
+
+// Min returns the smallest value of the expression for all documents
+func Min(expression interface{}) Accumulator {
+	return Accumulator(&bson.M{
+		"$min": expression,
+	})
+}
+
+// Max returns the largest value of the expression for all documents
+func Max(expression interface{}) Accumulator {
+	return Accumulator(&bson.M{
+		"$max": expression,
+	})
+}
+
+// First returns the value of the expression for the first document. Only meaningful if the documents are sorted
+func First(expression interface{}) Accumulator {
+	return Accumulator(&bson.M{
+		"$first": expression,
+	})
+}
+
+// Last returns the value of the expression for the last document. Only meaningful if the documents are sorted
+func Last(expression interface{}) Accumulator {
+	return Accumulator(&bson.M{
+		"$last": expression,
+	})
+}
+
+// Push returns an array with the value of the expression for all documents
+func Push(expression interface{}) Accumulator {
+	return Accumulator(&bson.M{
+		"$push": expression,
+	})
+}
+
+// AddToSet returns an array with all unique values of the expression for all documents
+func AddToSet(expression interface{}) Accumulator {
+	return Accumulator(&bson.M{
+		"$addToSet": expression,
+	})
+}
+
+// StdDevPopulation returns the population standard deviation of the expression for all documents
+func StdDevPopulation(expression interface{}) Accumulator {
+	return Accumulator(&bson.M{
+		"$stdDevPop": expression,
+	})
+}
+
+// StdDevSample returns the sample standard deviation of the expression for all documents
+func StdDevSample(expression interface{}) Accumulator {
+	return Accumulator(&bson.M{
+		"$stdDevSamp": expression,
+	})
+}
+
+// MergeObjects returns a single object with the fields of the expression for all documents.
+// Later documents overwrite fields of earlier documents
+func MergeObjects(expression interface{}) Accumulator {
+	return Accumulator(&bson.M{
+		"$mergeObjects": expression,
+	})
+}
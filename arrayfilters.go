@@ -0,0 +1,30 @@
+package wrap
+
+import (
+	"github.com/lucacasonato/wrap/update"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// arrayFilters converts the array filters passed with the updates to the driver option, or nil if there are no filters
+func arrayFilters(updates []update.Update) *options.ArrayFilters {
+	filters := update.ArrayFiltersOf(updates...)
+
+	if len(filters) == 0 {
+		return nil
+	}
+
+	af := options.ArrayFilters{Filters: []interface{}{}}
+
+	for _, f := range filters {
+		af.Filters = append(af.Filters, f)
+	}
+
+	return &af
+}
+
+func updateOptions(upsert bool, updates []update.Update) *options.UpdateOptions {
+	opts := options.Update().SetUpsert(upsert)
+	opts.ArrayFilters = arrayFilters(updates)
+
+	return opts
+}
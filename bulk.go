@@ -1,110 +1,394 @@
 package wrap
 
 import (
-	"github.com/imdario/mergo"
+	"sort"
+	"time"
+
 	"github.com/lucacasonato/wrap/filter"
 	"github.com/lucacasonato/wrap/update"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// Bulk is used to do bulk writes
-func (c *Collection) Bulk(run func(collection *BulkCollection) error, ordered bool) error {
+// BulkOption configures how a bulk write is executed
+type BulkOption func(opts *bulkOptions)
+
+type bulkOptions struct {
+	chunkSize     int
+	chunkBytes    int
+	workers       int
+	flushInterval time.Duration
+}
+
+// ChunkSize sends the queued operations to the server every time n operations are queued
+func ChunkSize(n int) BulkOption {
+	return func(opts *bulkOptions) {
+		opts.chunkSize = n
+	}
+}
+
+// ChunkBytes sends the queued operations to the server every time the approximate
+// size of the queued operations reaches n bytes
+func ChunkBytes(n int) BulkOption {
+	return func(opts *bulkOptions) {
+		opts.chunkBytes = n
+	}
+}
+
+// Workers sends up to n chunks to the server concurrently. Only used for unordered bulk writes
+func Workers(n int) BulkOption {
+	return func(opts *bulkOptions) {
+		opts.workers = n
+	}
+}
+
+// Bulk is used to do bulk writes. By default all operations are sent to the
+// server in one batch after run returns. Use ChunkSize or ChunkBytes to send
+// operations while they are being queued. The result contains all chunks that
+// were written, also if an error is returned
+func (c *Collection) Bulk(run func(collection *BulkCollection) error, ordered bool, opts ...BulkOption) (*BulkResult, error) {
+	bulkCollection := c.bulkCollection(ordered, opts)
+
+	err := run(bulkCollection)
+	if err == nil {
+		err = bulkCollection.flush()
+	} else {
+		bulkCollection.discard()
+	}
+
+	result, writeErr := bulkCollection.wait()
+	if writeErr != nil {
+		return result, writeErr
+	}
+
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+func (c *Collection) bulkCollection(ordered bool, opts []BulkOption) *BulkCollection {
 	bulkCollection := &BulkCollection{
 		Collection: c,
 		models:     []mongo.WriteModel{},
+		ordered:    ordered,
+		result: &BulkResult{
+			Upserted: map[int]*Document{},
+		},
 	}
 
-	err := run(bulkCollection)
-	if err != nil {
-		return err
+	for _, opt := range opts {
+		opt(&bulkCollection.options)
+	}
+
+	if !ordered && bulkCollection.options.workers > 1 {
+		bulkCollection.workers = make(chan struct{}, bulkCollection.options.workers)
 	}
 
-	_, err = c.collection.BulkWrite(bulkCollection.Collection.Database.Client.ctx(), bulkCollection.models, options.BulkWrite().SetOrdered(ordered))
+	return bulkCollection
+}
+
+// wait waits for all chunks that are being written and returns the result of all written chunks
+func (c *BulkCollection) wait() (*BulkResult, error) {
+	c.wg.Wait()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	result := *c.result
+	result.Errors = append([]*BulkError{}, c.result.Errors...)
+	result.Upserted = map[int]*Document{}
+
+	for i, doc := range c.result.Upserted {
+		result.Upserted[i] = doc
+	}
+
+	sort.Slice(result.Errors, func(i, j int) bool {
+		return result.Errors[i].Index < result.Errors[j].Index
+	})
+
+	sort.Slice(c.inserted, func(i, j int) bool {
+		return c.inserted[i].index < c.inserted[j].index
+	})
+
+	result.InsertedIDs = make([]*Document, len(c.inserted))
+
+	for i, inserted := range c.inserted {
+		result.InsertedIDs[i] = inserted.document
+	}
+
+	return &result, c.err
+}
+
+// insertedID is the document inserted by the operation at the index
+type insertedID struct {
+	index    int
+	document *Document
+}
+
+// queue adds a model and flushes the queued models if the chunk is full. The values
+// are the documents of the model, counted towards ChunkBytes
+func (c *BulkCollection) queue(model mongo.WriteModel, values ...interface{}) error {
+	size := 0
+
+	if c.options.chunkBytes > 0 {
+		for _, v := range values {
+			raw, err := bson.Marshal(v)
+			if err != nil {
+				return err
+			}
+
+			size += len(raw)
+		}
+	}
+
+	c.queueLock.Lock()
+	defer c.queueLock.Unlock()
+
+	return c.push(model, nil, size)
+}
+
+// push is queue for callers that hold the queue lock. insertID is the id of the inserted document for
+// insert models and size is the size of the documents of the model in bytes
+func (c *BulkCollection) push(model mongo.WriteModel, insertID interface{}, size int) error {
+	if c.closed {
+		return ErrClosed
+	}
+
+	// an error of a periodic flush is returned to the next caller
+	if c.flushErr != nil {
+		return c.flushErr
+	}
+
+	c.models = append(c.models, model)
+	c.insertIDs = append(c.insertIDs, insertID)
+	c.size += size
+
+	if (c.options.chunkSize > 0 && len(c.models) >= c.options.chunkSize) ||
+		(c.options.chunkBytes > 0 && c.size >= c.options.chunkBytes) {
+		return c.flush()
+	}
+
+	return nil
+}
+
+// flush sends the queued models to the server. The caller must hold the queue lock
+func (c *BulkCollection) flush() error {
+	err := c.stopErr()
 	if err != nil {
+		c.discard()
+
 		return err
 	}
 
+	if len(c.models) == 0 {
+		return nil
+	}
+
+	models := c.models
+	insertIDs := c.insertIDs
+	offset := c.offset
+
+	c.models = []mongo.WriteModel{}
+	c.insertIDs = []interface{}{}
+	c.offset += len(models)
+	c.size = 0
+
+	if c.workers == nil {
+		c.write(models, insertIDs, offset)
+
+		return c.stopErr()
+	}
+
+	c.workers <- struct{}{}
+	c.wg.Add(1)
+
+	go func() {
+		defer func() {
+			<-c.workers
+			c.wg.Done()
+		}()
+
+		c.write(models, insertIDs, offset)
+	}()
+
 	return nil
 }
 
-// UpdateDocumentsWhere the filter matches
-func (c *BulkCollection) UpdateDocumentsWhere(filter filter.Filter, upsert bool, updates ...update.Update) error {
-	var final = bson.M{}
+// discard drops the queued models without sending them and counts them as not executed. The caller must hold the queue lock
+func (c *BulkCollection) discard() {
+	c.lock.Lock()
+	c.result.NotExecuted += len(c.models)
+	c.lock.Unlock()
+
+	c.offset += len(c.models)
+	c.models = []mongo.WriteModel{}
+	c.insertIDs = []interface{}{}
+	c.size = 0
+}
+
+// write sends a chunk of models to the server and adds the outcome to the result
+func (c *BulkCollection) write(models []mongo.WriteModel, insertIDs []interface{}, offset int) {
+	ctx, cancel := c.Collection.Database.Client.ctx()
+	defer cancel()
+
+	res, err := c.Collection.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(c.ordered))
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if res != nil {
+		c.result.Inserted += res.InsertedCount
+		c.result.Matched += res.MatchedCount
+		c.result.Modified += res.ModifiedCount
+		c.result.Deleted += res.DeletedCount
+		c.result.UpsertedCount += res.UpsertedCount
+
+		for i, id := range res.UpsertedIDs {
+			doc, err := c.Collection.documentFromID(id)
+			if err != nil {
+				c.result.Errors = append(c.result.Errors, &BulkError{Index: offset + int(i), Err: err})
+				continue
+			}
+
+			c.result.Upserted[offset+int(i)] = doc
+		}
+	}
+
+	// executed is the amount of operations the server attempted
+	executed := len(models)
+	failed := map[int]bool{}
+
+	bwe, isWriteErr := err.(mongo.BulkWriteException)
+	if isWriteErr {
+		for _, we := range bwe.WriteErrors {
+			c.result.Errors = append(c.result.Errors, &BulkError{
+				Index: offset + we.Index,
+				Err:   classify(we.WriteError, we.Code, we.Message),
+			})
+
+			failed[we.Index] = true
+
+			// ordered bulk writes stop at the first error
+			if c.ordered && we.Index+1 < executed {
+				executed = we.Index + 1
+			}
+		}
+
+		c.result.NotExecuted += len(models) - executed
+	} else if err != nil {
+		// it is unknown which operations were written
+		executed = 0
+	}
+
+	for i := 0; i < executed; i++ {
+		if insertIDs[i] == nil || failed[i] {
+			continue
+		}
 
-	for _, u := range updates {
-		err := mergo.Merge(&final, u)
+		doc, err := c.Collection.documentFromID(insertIDs[i])
 		if err != nil {
-			return err
+			c.result.Errors = append(c.result.Errors, &BulkError{Index: offset + i, Err: err})
+			continue
 		}
+
+		c.inserted = append(c.inserted, insertedID{index: offset + i, document: doc})
+	}
+
+	if err != nil && c.err == nil {
+		c.err = convertError(err)
+		// unordered bulk writes continue after errors of single operations
+		c.stop = c.ordered || !isWriteErr
 	}
+}
+
+// stopErr returns the error that stopped the bulk write, if any
+func (c *BulkCollection) stopErr() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 
-	c.models = append(c.models, mongo.NewUpdateManyModel().SetFilter(filter).SetUpdate(final).SetUpsert(upsert))
+	if c.stop {
+		return c.err
+	}
 
 	return nil
 }
 
+// UpdateDocumentsWhere the filter matches
+func (c *BulkCollection) UpdateDocumentsWhere(filter filter.Filter, upsert bool, updates ...update.Update) error {
+	final, err := update.Combine(updates...)
+	if err != nil {
+		return err
+	}
+
+	model := mongo.NewUpdateManyModel().SetFilter(filter).SetUpdate(final).SetUpsert(upsert)
+	model.ArrayFilters = arrayFilters(updates)
+
+	return c.queue(model, filter, final)
+}
+
 // DeleteDocumentsWhere the filter matches
-func (c *BulkCollection) DeleteDocumentsWhere(filter filter.Filter) {
-	c.models = append(c.models, mongo.NewDeleteManyModel().SetFilter(filter))
+func (c *BulkCollection) DeleteDocumentsWhere(filter filter.Filter) error {
+	return c.queue(mongo.NewDeleteManyModel().SetFilter(filter), filter)
 }
 
 // Document get a single document from a collection
-func (c *BulkCollection) Document(id string) *BulkDocument {
+func (c *BulkCollection) Document(id ID) *BulkDocument {
 	return &BulkDocument{
 		ID:         id,
 		Collection: c,
 	}
 }
 
-// Add a document with a certain value
-func (c *BulkCollection) Add(data interface{}) {
-	c.models = append(c.models, mongo.NewInsertOneModel().SetDocument(data))
+// Add a document with a certain value. A document ID is generated if the data does not contain one
+func (c *BulkCollection) Add(data interface{}) error {
+	doc, id, err := ensureID(data)
+	if err != nil {
+		return err
+	}
+
+	c.queueLock.Lock()
+	defer c.queueLock.Unlock()
+
+	return c.push(mongo.NewInsertOneModel().SetDocument(doc), id, len(doc))
 }
 
 // Set a document to a certain value
 func (d *BulkDocument) Set(data interface{}) error {
-	objID, err := primitive.ObjectIDFromHex(d.ID)
+	filter, err := idFilter(d.ID)
 	if err != nil {
 		return err
 	}
 
-	d.Collection.models = append(d.Collection.models, mongo.NewReplaceOneModel().SetFilter(bson.M{"_id": objID}).SetReplacement(data).SetUpsert(true))
-
-	return nil
+	return d.Collection.queue(mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(data).SetUpsert(true), data)
 }
 
 // Update a document using the update operators
 func (d *BulkDocument) Update(upsert bool, updates ...update.Update) error {
-	objID, err := primitive.ObjectIDFromHex(d.ID)
+	filter, err := idFilter(d.ID)
 	if err != nil {
 		return err
 	}
 
-	var final = bson.M{}
-
-	for _, update := range updates {
-		err := mergo.Merge(&final, update)
-		if err != nil {
-			return err
-		}
+	final, err := update.Combine(updates...)
+	if err != nil {
+		return err
 	}
 
-	d.Collection.models = append(d.Collection.models, mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": objID}).SetUpdate(final).SetUpsert(upsert))
+	model := mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(final).SetUpsert(upsert)
+	model.ArrayFilters = arrayFilters(updates)
 
-	return nil
+	return d.Collection.queue(model, final)
 }
 
 // Delete a document from a collection
 func (d *BulkDocument) Delete() error {
-	objID, err := primitive.ObjectIDFromHex(d.ID)
+	filter, err := idFilter(d.ID)
 	if err != nil {
 		return err
 	}
 
-	d.Collection.models = append(d.Collection.models, mongo.NewDeleteOneModel().SetFilter(bson.M{"_id": objID}))
-
-	return nil
+	return d.Collection.queue(mongo.NewDeleteOneModel().SetFilter(filter), filter)
 }
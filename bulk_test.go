@@ -1,6 +1,7 @@
 package wrap_test
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/lucacasonato/wrap"
@@ -12,15 +13,15 @@ func TestBulkWrite(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	err = collection.Bulk(func(c *wrap.BulkCollection) error {
-		c.Add(map[string]interface{}{
+	res, err := collection.Bulk(func(c *wrap.BulkCollection) error {
+		err := c.Add(map[string]interface{}{
 			"name": "the red fish",
 		})
 		if err != nil {
 			return err
 		}
 
-		blueFish := c.Document("0123456789abcdef12345678")
+		blueFish := c.Document(wrap.StringID("the blue fish"))
 
 		err = blueFish.Set(map[string]interface{}{
 			"name": "the blue fish",
@@ -34,4 +35,111 @@ func TestBulkWrite(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+
+	if res.Inserted != 1 || len(res.InsertedIDs) != 1 {
+		t.Fatalf("expected 1 inserted document, got %+v", res)
+	}
+
+	_, err = res.InsertedIDs[0].Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = collection.Database.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBulkWriteErrors(t *testing.T) {
+	collection, err := createCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := collection.Bulk(func(c *wrap.BulkCollection) error {
+		for _, name := range []string{"the red fish", "the blue fish", "the red fish"} {
+			err := c.Add(map[string]interface{}{
+				"_id": name,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, false)
+	if err == nil {
+		t.Fatal("bulk did not error on a duplicate key")
+	}
+
+	if res.Inserted != 2 || len(res.Errors) != 1 {
+		t.Fatalf("expected 2 inserted documents and 1 error, got %+v", res)
+	}
+
+	if res.Errors[0].Index != 2 || !errors.Is(res.Errors[0], wrap.ErrDuplicateKey) {
+		t.Fatalf("unexpected bulk error %v", res.Errors[0])
+	}
+
+	if len(res.InsertedIDs) != 2 || !res.InsertedIDs[1].ID.Equal(wrap.StringID("the blue fish")) {
+		t.Fatalf("unexpected inserted ids %v", res.InsertedIDs)
+	}
+
+	// an ordered bulk write stops at the first error, the operations after it are not inserted
+	res, err = collection.Bulk(func(c *wrap.BulkCollection) error {
+		for _, name := range []string{"the green fish", "the red fish", "the yellow fish"} {
+			err := c.Add(map[string]interface{}{
+				"_id": name,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, true)
+	if err == nil {
+		t.Fatal("bulk did not error on a duplicate key")
+	}
+
+	if res.Inserted != 1 || len(res.InsertedIDs) != 1 || !res.InsertedIDs[0].ID.Equal(wrap.StringID("the green fish")) {
+		t.Fatalf("unexpected ordered result %+v", res)
+	}
+
+	err = collection.Database.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBulkWriteChunked(t *testing.T) {
+	collection, err := createCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := collection.Bulk(func(c *wrap.BulkCollection) error {
+		for i := 0; i < 1000; i++ {
+			err := c.Add(map[string]interface{}{
+				"number": i,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, false, wrap.ChunkSize(100), wrap.ChunkBytes(4096), wrap.Workers(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Inserted != 1000 || len(res.InsertedIDs) != 1000 {
+		t.Fatalf("expected 1000 inserted documents, got %d", res.Inserted)
+	}
+
+	err = collection.Database.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
 }
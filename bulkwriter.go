@@ -0,0 +1,111 @@
+package wrap
+
+import (
+	"time"
+)
+
+// BulkWriter is a long lived bulk write. Operations can be queued from
+// multiple goroutines and are sent to the server when a chunk is full or
+// the flush interval has passed. Queueing an operation blocks while the
+// chunk is being written or all workers are busy
+type BulkWriter struct {
+	*BulkCollection
+
+	done chan struct{}
+}
+
+// FlushInterval sends the queued operations to the server at least every interval. Only used for bulk writers
+func FlushInterval(interval time.Duration) BulkOption {
+	return func(opts *bulkOptions) {
+		opts.flushInterval = interval
+	}
+}
+
+// BulkWriter creates a bulk writer on the collection. The bulk writer must be closed once it is no longer needed
+func (c *Collection) BulkWriter(ordered bool, opts ...BulkOption) *BulkWriter {
+	w := &BulkWriter{
+		BulkCollection: c.bulkCollection(ordered, opts),
+		done:           make(chan struct{}),
+	}
+
+	if w.options.flushInterval > 0 {
+		go w.flushPeriodically(w.options.flushInterval)
+	}
+
+	return w
+}
+
+func (w *BulkWriter) flushPeriodically(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.queueLock.Lock()
+			err := w.flush()
+			if err != nil && w.flushErr == nil {
+				w.flushErr = err
+			}
+			w.queueLock.Unlock()
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// Flush sends all queued operations to the server, waits for them to be written and
+// returns the result of the operations written since the previous flush. Indices in
+// the result are relative to the first operation after the previous flush. Operations of an
+// ordered bulk writer that were queued after an operation failed are dropped and counted in
+// NotExecuted
+func (w *BulkWriter) Flush() (*BulkResult, error) {
+	w.queueLock.Lock()
+	defer w.queueLock.Unlock()
+
+	err := w.flush()
+	if w.flushErr != nil {
+		err = w.flushErr
+	}
+
+	result, writeErr := w.wait()
+
+	w.reset()
+
+	if writeErr != nil {
+		return result, writeErr
+	}
+
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// reset clears the result and errors after a flush. The caller must hold the queue lock
+func (w *BulkWriter) reset() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.result = &BulkResult{
+		Upserted: map[int]*Document{},
+	}
+	w.inserted = nil
+	w.err = nil
+	w.stop = false
+	w.offset = 0
+	w.flushErr = nil
+}
+
+// Close flushes the bulk writer and stops it. Operations queued after Close return ErrClosed
+func (w *BulkWriter) Close() (*BulkResult, error) {
+	w.queueLock.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.done)
+	}
+	w.queueLock.Unlock()
+
+	return w.Flush()
+}
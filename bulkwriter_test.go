@@ -0,0 +1,236 @@
+package wrap_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lucacasonato/wrap"
+)
+
+func TestBulkWriter(t *testing.T) {
+	collection, err := createCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer := collection.BulkWriter(false, wrap.ChunkSize(50), wrap.Workers(2), wrap.FlushInterval(10*time.Millisecond))
+
+	var wg sync.WaitGroup
+
+	for p := 0; p < 4; p++ {
+		wg.Add(1)
+
+		go func(p int) {
+			defer wg.Done()
+
+			for i := 0; i < 100; i++ {
+				err := writer.Add(map[string]interface{}{
+					"producer": p,
+					"number":   i,
+				})
+				if err != nil {
+					t.Error(err)
+				}
+			}
+		}(p)
+	}
+
+	wg.Wait()
+
+	res, err := writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Inserted != 400 || len(res.InsertedIDs) != 400 {
+		t.Fatalf("expected 400 inserted documents, got %d", res.Inserted)
+	}
+
+	err = writer.Add(map[string]interface{}{})
+	if !errors.Is(err, wrap.ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+
+	err = collection.Database.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBulkWriterFlush(t *testing.T) {
+	collection, err := createCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer := collection.BulkWriter(true)
+
+	for _, batch := range []int{3, 2} {
+		for i := 0; i < batch; i++ {
+			err := writer.Add(map[string]interface{}{"number": i})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		res, err := writer.Flush()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.Inserted != int64(batch) || len(res.InsertedIDs) != batch {
+			t.Fatalf("expected %d inserted documents since the previous flush, got %+v", batch, res)
+		}
+	}
+
+	err = writer.Document(wrap.StringID("the red fish")).Set(map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.UpsertedCount != 1 || res.Upserted[0] == nil {
+		t.Fatalf("expected an upsert at index 0, got %+v", res)
+	}
+
+	err = collection.Database.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBulkWriterPeriodicFlushError(t *testing.T) {
+	collection, err := createCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer := collection.BulkWriter(true, wrap.FlushInterval(10*time.Millisecond))
+
+	for i := 0; i < 2; i++ {
+		err := writer.Add(map[string]interface{}{"_id": "the red fish"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	err = writer.Add(map[string]interface{}{"_id": "the blue fish"})
+	if !errors.Is(err, wrap.ErrDuplicateKey) {
+		t.Fatalf("expected the error of the periodic flush, got %v", err)
+	}
+
+	res, err := writer.Close()
+	if !errors.Is(err, wrap.ErrDuplicateKey) {
+		t.Fatalf("expected the error of the periodic flush, got %v", err)
+	}
+
+	if res.Inserted != 1 || len(res.Errors) != 1 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+
+	err = collection.Database.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBulkWriterOrderedFailure(t *testing.T) {
+	collection, err := createCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer collection.Database.Delete()
+
+	writer := collection.BulkWriter(true, wrap.ChunkSize(2))
+
+	for _, id := range []string{"a", "a"} {
+		err = writer.Add(map[string]interface{}{"_id": id})
+	}
+
+	if !errors.Is(err, wrap.ErrDuplicateKey) {
+		t.Fatalf("expected the error of the full chunk, got %v", err)
+	}
+
+	err = writer.Add(map[string]interface{}{"_id": "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := writer.Flush()
+	if !errors.Is(err, wrap.ErrDuplicateKey) {
+		t.Fatalf("expected the error that stopped the write, got %v", err)
+	}
+
+	if res.Inserted != 1 || res.NotExecuted != 1 || len(res.Errors) != 1 || res.Errors[0].Index != 1 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+
+	err = writer.Add(map[string]interface{}{"_id": "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Inserted != 1 || res.NotExecuted != 0 || len(res.InsertedIDs) != 1 {
+		t.Fatalf("expected only the operation queued after the flush, got %+v", res)
+	}
+
+	_, err = collection.Document(wrap.StringID("b")).Get()
+	if !errors.Is(err, wrap.ErrNotFound) {
+		t.Fatalf("expected the operation queued after the failure to be dropped, got %v", err)
+	}
+}
+
+func TestBulkWriterChunkBytes(t *testing.T) {
+	collection, err := createCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer collection.Database.Delete()
+
+	_, err = collection.Document(wrap.StringID("a")).Set(map[string]interface{}{"name": "nemo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer := collection.BulkWriter(true, wrap.ChunkBytes(1))
+
+	// the filter of a delete counts towards the chunk size, so it is written right away
+	err = writer.Document(wrap.StringID("a")).Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = collection.Document(wrap.StringID("a")).Get()
+	if !errors.Is(err, wrap.ErrNotFound) {
+		t.Fatalf("expected the delete to be written, got %v", err)
+	}
+
+	err = writer.Document(wrap.StringID("b")).Set(map[string]interface{}{"fins": make(chan int)})
+	if err == nil {
+		t.Fatal("expected an error for a value that can not be encoded")
+	}
+
+	res, err := writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Deleted != 1 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+}
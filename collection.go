@@ -3,10 +3,9 @@ package wrap
 import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 
-	"github.com/imdario/mergo"
 	"github.com/lucacasonato/wrap/filter"
+	"github.com/lucacasonato/wrap/geo"
 	"github.com/lucacasonato/wrap/update"
 )
 
@@ -35,7 +34,43 @@ func (c *Collection) All() *CollectionQuery {
 	}
 }
 
-// CreateIndex for a single or group of fields
+// GeoNear returns an abstract of the collection of documents sorted by distance to the point, with the
+// distance in meters stored in distanceField. If field is empty the only geo index of the collection is used.
+// A minDistance or maxDistance of 0 means no limit. If query is not nil only documents matching it are returned.
+// Distances are calculated on a sphere, so only 2dsphere indexes are supported
+func (c *Collection) GeoNear(field string, point geo.Point, distanceField string, minDistance float64, maxDistance float64, query filter.Filter) *CollectionQuery {
+	geoNear := bson.M{
+		"near":          point,
+		"distanceField": distanceField,
+		"spherical":     true,
+	}
+
+	if field != "" {
+		geoNear["key"] = field
+	}
+
+	if minDistance > 0 {
+		geoNear["minDistance"] = minDistance
+	}
+
+	if maxDistance > 0 {
+		geoNear["maxDistance"] = maxDistance
+	}
+
+	if query != nil {
+		geoNear["query"] = query
+	}
+
+	return &CollectionQuery{
+		Collection: c,
+		pipes: []*bson.M{&bson.M{
+			"$geoNear": geoNear,
+		}},
+	}
+}
+
+// CreateIndex for a single or group of fields. The order of the fields in a map is not defined,
+// use CreateIndexes for compound indexes
 func (c *Collection) CreateIndex(fields map[string]Index) error {
 	i := bson.M{}
 
@@ -43,11 +78,14 @@ func (c *Collection) CreateIndex(fields map[string]Index) error {
 		i[field] = index
 	}
 
-	_, err := c.collection.Indexes().CreateOne(c.Database.Client.ctx(), mongo.IndexModel{
+	ctx, cancel := c.Database.Client.ctx()
+	defer cancel()
+
+	_, err := c.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
 		Keys: i,
 	})
 	if err != nil {
-		return err
+		return convertError(err)
 	}
 
 	return nil
@@ -55,34 +93,44 @@ func (c *Collection) CreateIndex(fields map[string]Index) error {
 
 // Delete a collection
 func (c *Collection) Delete() error {
-	return c.collection.Drop(c.Database.Client.ctx())
+	ctx, cancel := c.Database.Client.ctx()
+	defer cancel()
+
+	err := c.collection.Drop(ctx)
+	if err != nil {
+		return convertError(err)
+	}
+
+	return nil
 }
 
 // UpdateDocumentsWhere the filter matches
-func (c *Collection) UpdateDocumentsWhere(filter filter.Filter, upsert bool, updates ...update.Update) error {
-	var final = bson.M{}
-
-	for _, u := range updates {
-		err := mergo.Merge(&final, u)
-		if err != nil {
-			return err
-		}
+func (c *Collection) UpdateDocumentsWhere(filter filter.Filter, upsert bool, updates ...update.Update) (*UpdateResult, error) {
+	final, err := update.Combine(updates...)
+	if err != nil {
+		return nil, err
 	}
 
-	_, err := c.collection.UpdateMany(c.Database.Client.ctx(), filter, final, options.Update().SetUpsert(upsert))
+	ctx, cancel := c.Database.Client.ctx()
+	defer cancel()
+
+	res, err := c.collection.UpdateMany(ctx, filter, final, updateOptions(upsert, updates))
 	if err != nil {
-		return err
+		return nil, convertError(err)
 	}
 
-	return nil
+	return c.updateResult(res)
 }
 
 // DeleteDocumentsWhere the filter matches
-func (c *Collection) DeleteDocumentsWhere(filter filter.Filter) error {
-	_, err := c.collection.DeleteMany(c.Database.Client.ctx(), filter)
+func (c *Collection) DeleteDocumentsWhere(filter filter.Filter) (*DeleteResult, error) {
+	ctx, cancel := c.Database.Client.ctx()
+	defer cancel()
+
+	res, err := c.collection.DeleteMany(ctx, filter)
 	if err != nil {
-		return err
+		return nil, convertError(err)
 	}
 
-	return nil
+	return deleteResult(res), nil
 }
@@ -2,8 +2,13 @@ package wrap_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/lucacasonato/wrap"
+	"github.com/lucacasonato/wrap/filter"
+	"github.com/lucacasonato/wrap/geo"
+	"github.com/lucacasonato/wrap/types"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 func createCollection() (*wrap.Collection, error) {
@@ -37,3 +42,384 @@ func TestCollectionDelete(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// Store with a location
+type Store struct {
+	Name     string    `bson:"name"`
+	Location geo.Point `bson:"location"`
+	Distance float64   `bson:"distance,omitempty"`
+}
+
+func TestCollectionGeo(t *testing.T) {
+	collection, err := createCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stores := collection.Database.Collection("stores")
+
+	err = stores.CreateIndex(map[string]wrap.Index{
+		"location": wrap.GeoSphereIndex,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, store := range []Store{
+		{Name: "amsterdam", Location: geo.NewPoint(4.895168, 52.370216)},
+		{Name: "utrecht", Location: geo.NewPoint(5.121420, 52.090737)},
+		{Name: "new york", Location: geo.NewPoint(-74.005974, 40.712776)},
+	} {
+		_, err = stores.Add(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	iterator, err := stores.GeoNear("location", geo.NewPoint(4.9, 52.37), "distance", 0, 100000, nil).DocumentIterator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iterator.Close()
+
+	names := []string{}
+
+	for iterator.Next() {
+		store := Store{}
+
+		err := iterator.DataTo(&store)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names = append(names, store.Name)
+	}
+
+	if len(names) != 2 || names[0] != "amsterdam" {
+		t.Fatalf("unexpected stores %v", names)
+	}
+
+	for _, f := range []filter.Filter{
+		filter.Near("location", geo.NewPoint(4.9, 52.37), 0, 100000),
+		filter.GeoWithin("location", geo.NewPolygon([]geo.Position{{4, 52}, {6, 52}, {6, 53}, {4, 53}, {4, 52}})),
+		filter.GeoWithinCenterSphere("location", geo.Position{4.9, 52.37}, geo.KilometersToRadians(100)),
+	} {
+		iterator, err := stores.Where(f).DocumentIterator()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		count := 0
+		for iterator.Next() {
+			count++
+		}
+
+		iterator.Close()
+
+		if count != 2 {
+			t.Fatalf("expected 2 stores, got %d", count)
+		}
+	}
+
+	err = collection.Database.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectionIndexes(t *testing.T) {
+	collection, err := createCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer collection.Database.Delete()
+
+	indexed := collection.Database.Collection("indexed")
+
+	err = indexed.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexes := []wrap.IndexDefinition{
+		{
+			Keys: []wrap.IndexKey{
+				{Field: "species", Kind: wrap.AscendingIndex},
+				{Field: "weight", Kind: wrap.DescendingIndex},
+			},
+		},
+		{
+			Keys:          []wrap.IndexKey{{Field: "tag", Kind: wrap.AscendingIndex}},
+			Name:          "tag_unique",
+			Unique:        true,
+			PartialFilter: filter.Exists("tag", true),
+			Collation:     &wrap.Collation{Locale: "en", Strength: 2},
+		},
+		{
+			Keys:        []wrap.IndexKey{{Field: "created", Kind: wrap.AscendingIndex}},
+			ExpireAfter: time.Hour,
+		},
+		{
+			Keys: []wrap.IndexKey{
+				{Field: "name", Kind: wrap.TextIndex},
+				{Field: "description", Kind: wrap.TextIndex},
+			},
+			Weights:         map[string]int{"name": 5},
+			DefaultLanguage: "dutch",
+		},
+	}
+
+	plan, err := indexed.EnsureIndexes(indexes...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plan.Create) != 4 || len(plan.Drop) != 0 {
+		t.Fatalf("unexpected plan %+v", plan)
+	}
+
+	plan, err = indexed.PlanIndexes(indexes...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plan.Create) != 0 || len(plan.Drop) != 0 {
+		t.Fatalf("expected no changes, got %+v", plan)
+	}
+
+	list, err := indexed.ListIndexes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 5 {
+		t.Fatalf("expected 5 indexes, got %d", len(list))
+	}
+
+	indexes[0].Unique = true
+
+	plan, err = indexed.EnsureIndexes(indexes[:2]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plan.Create) != 1 || len(plan.Drop) != 3 || len(plan.Created) != 1 || len(plan.Dropped) != 3 {
+		t.Fatalf("unexpected plan %+v", plan)
+	}
+
+	_, err = indexed.CreateIndexes(wrap.IndexDefinition{
+		Keys:        []wrap.IndexKey{{Field: "created", Kind: wrap.AscendingIndex}},
+		ExpireAfter: 1500 * time.Millisecond,
+	})
+	if err == nil {
+		t.Fatal("expected an error for an expiry that is not a whole number of seconds")
+	}
+
+	err = indexed.DropIndex("tag_unique")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = indexed.DropAllIndexes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err = indexed.ListIndexes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 1 || list[0].Name != "_id_" {
+		t.Fatalf("expected only the _id index, got %+v", list)
+	}
+
+	expires := wrap.IndexDefinition{Keys: []wrap.IndexKey{{Field: "expires", Kind: wrap.AscendingIndex}}, TTL: true}
+
+	_, err = indexed.EnsureIndexes(expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err = indexed.PlanIndexes(expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plan.Create) != 0 || len(plan.Drop) != 0 {
+		t.Fatalf("expected the TTL index without expiry to match, got %+v", plan)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err = indexed.Add(map[string]interface{}{"code": "duplicate"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code := wrap.IndexDefinition{Keys: []wrap.IndexKey{{Field: "code", Kind: wrap.AscendingIndex}}}
+
+	_, err = indexed.EnsureIndexes(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code.Unique = true
+
+	plan, err = indexed.EnsureIndexes(code)
+	if err == nil {
+		t.Fatal("expected an error for a unique index on duplicate values")
+	}
+
+	if plan == nil || len(plan.Dropped) != 1 || len(plan.Created) != 0 {
+		t.Fatalf("expected the progress before the error, got %+v", plan)
+	}
+}
+
+func TestCollectionFilters(t *testing.T) {
+	collection, err := createCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer collection.Database.Delete()
+
+	numbers := collection.Database.Collection("numbers")
+
+	err = numbers.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 5; i++ {
+		_, err = numbers.Add(map[string]interface{}{"n": i})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for expected, f := range map[int]filter.Filter{
+		2: filter.In("n", 1, 2),
+		3: filter.NotIn("n", 1, 2),
+		0: filter.In("n"),
+		4: filter.NOT(filter.Equal("n", 3)),
+		1: filter.NOT(filter.NOT(filter.Equal("n", 3))),
+		5: filter.NOT(filter.AND(filter.Equal("n", 3), filter.Equal("n", 4))),
+	} {
+		count := 0
+
+		iterator, err := numbers.Where(f).DocumentIterator()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for iterator.Next() {
+			count++
+		}
+
+		err = iterator.Err()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		iterator.Close()
+
+		if count != expected {
+			t.Fatalf("expected %d documents, got %d", expected, count)
+		}
+	}
+}
+
+func countWhere(t *testing.T, collection *wrap.Collection, f filter.Filter) int {
+	iterator, err := collection.Where(f).DocumentIterator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iterator.Close()
+
+	count := 0
+
+	for iterator.Next() {
+		count++
+	}
+
+	err = iterator.Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return count
+}
+
+func TestCollectionFiltersNOT(t *testing.T) {
+	collection, err := createCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer collection.Database.Delete()
+
+	values := collection.Database.Collection("values")
+
+	documents := []map[string]interface{}{
+		{},
+		{"a": 1},
+		{"a": 5, "b": 2},
+		{"a": 6, "b": 6},
+		{"a": "fish"},
+		{"a": []interface{}{1, 2, 3}},
+		{"a": []interface{}{0, 0}},
+		{"a": []interface{}{map[string]interface{}{"b": 1, "c": 3}}},
+	}
+
+	for _, document := range documents {
+		_, err = values.Add(document)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, f := range map[string]filter.Filter{
+		"Equal":                 filter.Equal("a", 1),
+		"GreaterThan":           filter.GreaterThan("a", 1),
+		"LessThanOrEqual":       filter.LessThanOrEqual("a", 5),
+		"ArrayContains":         filter.ArrayContains("a", 1),
+		"ArrayNotContains":      filter.ArrayNotContains("a", 1),
+		"In":                    filter.In("a", 1, 2, "fish"),
+		"InNone":                filter.In("a"),
+		"NotIn":                 filter.NotIn("a", 1, 2),
+		"Exists":                filter.Exists("a", true),
+		"IsType":                filter.IsType("a", types.String),
+		"Regex":                 filter.Regex("a", "^fi"),
+		"Modulo":                filter.Modulo("a", 2, 0),
+		"ArrayAll":              filter.ArrayAll("a", []interface{}{1, 2}),
+		"ArraySize":             filter.ArraySize("a", 2),
+		"ArraySingleMatch":      filter.ArraySingleMatch("a", filter.Equal("b", 1), filter.GreaterThan("c", 2)),
+		"ArraySingleMatchValue": filter.ArraySingleMatch("a", filter.GreaterThan("", 1), filter.NOT(filter.Equal("", 3))),
+		"BitsAll1":              filter.BitsAll1("a", 1),
+		"GeoWithinBox":          filter.GeoWithinBox("a", geo.Position{-1, -1}, geo.Position{1, 1}),
+		"AND":                   filter.AND(filter.Equal("a", 5), filter.Equal("b", 2)),
+		"OR":                    filter.OR(filter.Equal("a", 1), filter.Equal("b", 2)),
+		"NOR":                   filter.NOR(filter.Equal("a", 1), filter.Equal("b", 2)),
+		"Expression":            filter.Expression(bson.M{"$eq": bson.A{"$a", "$b"}}),
+		"Schema":                filter.Schema(filter.NewSchema(types.Object).Required("b")),
+	} {
+		count := countWhere(t, values, f)
+		negated := countWhere(t, values, filter.NOT(f))
+
+		if count+negated != len(documents) {
+			t.Errorf("%s: %d documents match and %d documents match NOT, expected %d in total", name, count, negated, len(documents))
+		}
+
+		double := countWhere(t, values, filter.NOT(filter.NOT(f)))
+		if double != count {
+			t.Errorf("%s: expected NOT(NOT(f)) to match %d documents, got %d", name, count, double)
+		}
+
+		inOR := countWhere(t, values, filter.OR(filter.NOT(f), filter.Equal("z", 1)))
+		if inOR != negated {
+			t.Errorf("%s: expected NOT in OR to match %d documents, got %d", name, negated, inOR)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package wrap
+
+import (
+	"github.com/lucacasonato/wrap/filter"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// CollectionOption configures a collection when it is created or modified
+type CollectionOption func(opts *bson.D)
+
+// ValidationLevel sets which documents are validated
+type ValidationLevel string
+
+const (
+	// ValidationOff does not validate documents
+	ValidationOff ValidationLevel = "off"
+	// ValidationStrict validates all inserts and updates
+	ValidationStrict ValidationLevel = "strict"
+	// ValidationModerate validates inserts and updates of documents that are already valid
+	ValidationModerate ValidationLevel = "moderate"
+)
+
+// ValidationAction sets what happens with invalid documents
+type ValidationAction string
+
+const (
+	// ValidationError rejects invalid documents
+	ValidationError ValidationAction = "error"
+	// ValidationWarn accepts invalid documents and logs a warning on the server
+	ValidationWarn ValidationAction = "warn"
+)
+
+// Validator only allows documents that match the filter, for example a filter.Schema
+func Validator(validator filter.Filter) CollectionOption {
+	return func(opts *bson.D) {
+		*opts = append(*opts, bson.E{Key: "validator", Value: validator})
+	}
+}
+
+// Validation sets which documents are validated and what happens with invalid documents
+func Validation(level ValidationLevel, action ValidationAction) CollectionOption {
+	return func(opts *bson.D) {
+		*opts = append(*opts,
+			bson.E{Key: "validationLevel", Value: level},
+			bson.E{Key: "validationAction", Value: action},
+		)
+	}
+}
+
+func collectionOptions(opts []CollectionOption) bson.D {
+	d := bson.D{}
+
+	for _, opt := range opts {
+		opt(&d)
+	}
+
+	return d
+}
+
+// Modify changes the options of an existing collection, for example the validator
+func (c *Collection) Modify(opts ...CollectionOption) error {
+	command := bson.D{{Key: "collMod", Value: c.ID}}
+	command = append(command, collectionOptions(opts)...)
+
+	ctx, cancel := c.Database.Client.ctx()
+	defer cancel()
+
+	err := c.Database.database.RunCommand(ctx, command).Err()
+	if err != nil {
+		return convertError(err)
+	}
+
+	return nil
+}
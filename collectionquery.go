@@ -1,6 +1,11 @@
 package wrap
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/lucacasonato/wrap/accumulators"
+	"github.com/lucacasonato/wrap/filter"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
@@ -20,11 +25,71 @@ func Descending(field string) *Sorter {
 	return &Sorter{field, -1}
 }
 
+// Granularity is a series of preferred numbers used to calculate the boundaries of automatic buckets
+type Granularity string
+
+const (
+	// NoGranularity calculates the boundaries from the data
+	NoGranularity Granularity = ""
+	// R5 is the Renard series with 5 steps per power of ten
+	R5 Granularity = "R5"
+	// R10 is the Renard series with 10 steps per power of ten
+	R10 Granularity = "R10"
+	// R20 is the Renard series with 20 steps per power of ten
+	R20 Granularity = "R20"
+	// R40 is the Renard series with 40 steps per power of ten
+	R40 Granularity = "R40"
+	// R80 is the Renard series with 80 steps per power of ten
+	R80 Granularity = "R80"
+	// Series125 is the 1, 2, 5 series
+	Series125 Granularity = "1-2-5"
+	// E6 is the E series with 6 steps per power of ten
+	E6 Granularity = "E6"
+	// E12 is the E series with 12 steps per power of ten
+	E12 Granularity = "E12"
+	// E24 is the E series with 24 steps per power of ten
+	E24 Granularity = "E24"
+	// E48 is the E series with 48 steps per power of ten
+	E48 Granularity = "E48"
+	// E96 is the E series with 96 steps per power of ten
+	E96 Granularity = "E96"
+	// E192 is the E series with 192 steps per power of ten
+	E192 Granularity = "E192"
+	// PowersOf2 uses powers of two as boundaries
+	PowersOf2 Granularity = "POWERSOF2"
+)
+
+// WhenMatched is the action MergeInto takes if a document already exists in the target collection
+type WhenMatched string
+
+const (
+	// WhenMatchedReplace replaces the existing document
+	WhenMatchedReplace WhenMatched = "replace"
+	// WhenMatchedKeepExisting keeps the existing document
+	WhenMatchedKeepExisting WhenMatched = "keepExisting"
+	// WhenMatchedMerge merges the fields of both documents
+	WhenMatchedMerge WhenMatched = "merge"
+	// WhenMatchedFail stops the query with an error
+	WhenMatchedFail WhenMatched = "fail"
+)
+
+// WhenNotMatched is the action MergeInto takes if a document does not exist in the target collection
+type WhenNotMatched string
+
+const (
+	// WhenNotMatchedInsert adds the document
+	WhenNotMatchedInsert WhenNotMatched = "insert"
+	// WhenNotMatchedDiscard ignores the document
+	WhenNotMatchedDiscard WhenNotMatched = "discard"
+	// WhenNotMatchedFail stops the query with an error
+	WhenNotMatchedFail WhenNotMatched = "fail"
+)
+
 // Skip skips the first n documents
 func (cq *CollectionQuery) Skip(n int) *CollectionQuery {
 	c := *cq
 
-	c.pipes = append(c.pipes, &bson.M{
+	c.pipes = append(c.pipes[:len(c.pipes):len(c.pipes)], &bson.M{
 		"$skip": n,
 	})
 
@@ -35,7 +100,7 @@ func (cq *CollectionQuery) Skip(n int) *CollectionQuery {
 func (cq *CollectionQuery) Limit(n int) *CollectionQuery {
 	c := *cq
 
-	c.pipes = append(c.pipes, &bson.M{
+	c.pipes = append(c.pipes[:len(c.pipes):len(c.pipes)], &bson.M{
 		"$limit": n,
 	})
 
@@ -46,7 +111,7 @@ func (cq *CollectionQuery) Limit(n int) *CollectionQuery {
 func (cq *CollectionQuery) Count(field string) *CollectionQuery {
 	c := *cq
 
-	c.pipes = append(c.pipes, &bson.M{
+	c.pipes = append(c.pipes[:len(c.pipes):len(c.pipes)], &bson.M{
 		"$count": field,
 	})
 
@@ -57,7 +122,7 @@ func (cq *CollectionQuery) Count(field string) *CollectionQuery {
 func (cq *CollectionQuery) Sample(n int) *CollectionQuery {
 	c := *cq
 
-	c.pipes = append(c.pipes, &bson.M{
+	c.pipes = append(c.pipes[:len(c.pipes):len(c.pipes)], &bson.M{
 		"$sample": n,
 	})
 
@@ -74,7 +139,7 @@ func (cq *CollectionQuery) Sort(sorters ...*Sorter) *CollectionQuery {
 		finalSorters[s.field] = s.order
 	}
 
-	c.pipes = append(c.pipes, &bson.M{
+	c.pipes = append(c.pipes[:len(c.pipes):len(c.pipes)], &bson.M{
 		"$sort": finalSorters,
 	})
 
@@ -87,7 +152,7 @@ func (cq *CollectionQuery) Sort(sorters ...*Sorter) *CollectionQuery {
 func (cq *CollectionQuery) Join(localField string, foreignCollection string, foreignField string, as string) *CollectionQuery {
 	c := *cq
 
-	c.pipes = append(c.pipes, &bson.M{
+	c.pipes = append(c.pipes[:len(c.pipes):len(c.pipes)], &bson.M{
 		"$lookup": bson.M{
 			"from":         foreignCollection,
 			"localField":   localField,
@@ -99,11 +164,84 @@ func (cq *CollectionQuery) Join(localField string, foreignCollection string, for
 	return &c
 }
 
+// JoinQuery runs the foreign query for every document and adds the resulting documents to the
+// original document as an array under the 'as' key. The let variables are evaluated on the
+// original document and can be used in the foreign query with expressions.Variable, for example
+// in a filter.Expression. The foreign query must be built from a collection in the same database
+func (cq *CollectionQuery) JoinQuery(foreignQuery *CollectionQuery, let map[string]interface{}, as string) *CollectionQuery {
+	c := *cq
+
+	if foreignQuery.err != nil && c.err == nil {
+		c.err = foreignQuery.err
+	}
+
+	if foreignQuery.Collection.Database.ID != cq.Collection.Database.ID && c.err == nil {
+		c.err = fmt.Errorf("wrap: the query of JoinQuery is on database %s, expected database %s", foreignQuery.Collection.Database.ID, cq.Collection.Database.ID)
+	}
+
+	lookup := bson.M{
+		"from":     foreignQuery.Collection.ID,
+		"pipeline": foreignQuery.pipes,
+		"as":       as,
+	}
+
+	if len(let) > 0 {
+		lookup["let"] = let
+	}
+
+	c.pipes = append(c.pipes[:len(c.pipes):len(c.pipes)], &bson.M{
+		"$lookup": lookup,
+	})
+
+	return &c
+}
+
+// GraphJoin recursively searches the foreign collection. It starts with the documents where the value of
+// connectToField matches the startWith expression and then continues with the documents where the value of
+// connectToField matches the value of connectFromField of the previously found documents. All found documents
+// are added to the original document as an array under the 'as' key. The search stops after maxDepth
+// recursions, use a negative maxDepth for no limit. If depthField is not empty the recursion depth of every
+// found document is stored in that field. If restrict is not nil only documents matching it are searched.
+// The foreign collection must be in the same database
+func (cq *CollectionQuery) GraphJoin(foreignCollection *Collection, startWith interface{}, connectFromField string, connectToField string, as string, maxDepth int, depthField string, restrict filter.Filter) *CollectionQuery {
+	c := *cq
+
+	if foreignCollection.Database.ID != cq.Collection.Database.ID && c.err == nil {
+		c.err = fmt.Errorf("wrap: the collection of GraphJoin is in database %s, expected database %s", foreignCollection.Database.ID, cq.Collection.Database.ID)
+	}
+
+	graphLookup := bson.M{
+		"from":             foreignCollection.ID,
+		"startWith":        startWith,
+		"connectFromField": connectFromField,
+		"connectToField":   connectToField,
+		"as":               as,
+	}
+
+	if maxDepth >= 0 {
+		graphLookup["maxDepth"] = maxDepth
+	}
+
+	if depthField != "" {
+		graphLookup["depthField"] = depthField
+	}
+
+	if restrict != nil {
+		graphLookup["restrictSearchWithMatch"] = restrict
+	}
+
+	c.pipes = append(c.pipes[:len(c.pipes):len(c.pipes)], &bson.M{
+		"$graphLookup": graphLookup,
+	})
+
+	return &c
+}
+
 // Modify changes the data structure of the field like specified by the specification
 func (cq *CollectionQuery) Modify(spec map[string]interface{}) *CollectionQuery {
 	c := *cq
 
-	c.pipes = append(c.pipes, &bson.M{
+	c.pipes = append(c.pipes[:len(c.pipes):len(c.pipes)], &bson.M{
 		"$project": spec,
 	})
 
@@ -114,18 +252,187 @@ func (cq *CollectionQuery) Modify(spec map[string]interface{}) *CollectionQuery
 func (cq *CollectionQuery) AddFields(spec map[string]interface{}) *CollectionQuery {
 	c := *cq
 
-	c.pipes = append(c.pipes, &bson.M{
+	c.pipes = append(c.pipes[:len(c.pipes):len(c.pipes)], &bson.M{
 		"$addFields": spec,
 	})
 
 	return &c
 }
 
+// Group groups documents by the value of the key expression and returns one document per group.
+// The key is stored in the _id field and the value of each accumulator under its field name.
+// Use a nil key to compute the accumulators over all documents
+func (cq *CollectionQuery) Group(key interface{}, accumulators map[string]accumulators.Accumulator) *CollectionQuery {
+	c := *cq
+
+	group := bson.M{
+		"_id": key,
+	}
+
+	for field, accumulator := range accumulators {
+		group[field] = accumulator
+	}
+
+	c.pipes = append(c.pipes[:len(c.pipes):len(c.pipes)], &bson.M{
+		"$group": group,
+	})
+
+	return &c
+}
+
+// Bucket groups documents into buckets by the value of the groupBy expression. A document is put into
+// the bucket where boundaries[i] <= value < boundaries[i+1]. The boundaries must be sorted ascending.
+// Documents outside of all buckets are put into a bucket with the def value as _id, or cause an error if
+// def is nil. The lower boundary of a bucket is stored in the _id field and the value of each accumulator
+// under its field name. If output is empty the amount of documents is stored in the count field
+func (cq *CollectionQuery) Bucket(groupBy interface{}, boundaries []interface{}, def interface{}, output map[string]accumulators.Accumulator) *CollectionQuery {
+	c := *cq
+
+	bucket := bson.M{
+		"groupBy":    groupBy,
+		"boundaries": boundaries,
+	}
+
+	if def != nil {
+		bucket["default"] = def
+	}
+
+	if len(output) > 0 {
+		bucket["output"] = output
+	}
+
+	c.pipes = append(c.pipes[:len(c.pipes):len(c.pipes)], &bson.M{
+		"$bucket": bucket,
+	})
+
+	return &c
+}
+
+// BucketAuto groups documents into the specified amount of buckets by the value of the groupBy expression.
+// The boundaries are chosen so the documents are distributed evenly, rounded to the granularity.
+// The boundaries of a bucket are stored in the _id.min and _id.max fields and the value of each accumulator
+// under its field name. If output is empty the amount of documents is stored in the count field
+func (cq *CollectionQuery) BucketAuto(groupBy interface{}, buckets int, granularity Granularity, output map[string]accumulators.Accumulator) *CollectionQuery {
+	c := *cq
+
+	bucketAuto := bson.M{
+		"groupBy": groupBy,
+		"buckets": buckets,
+	}
+
+	if granularity != NoGranularity {
+		bucketAuto["granularity"] = granularity
+	}
+
+	if len(output) > 0 {
+		bucketAuto["output"] = output
+	}
+
+	c.pipes = append(c.pipes[:len(c.pipes):len(c.pipes)], &bson.M{
+		"$bucketAuto": bucketAuto,
+	})
+
+	return &c
+}
+
+// SortByCount groups documents by the value of the expression and returns one document per group with
+// the value in the _id field and the amount of documents in the count field, sorted by count descending
+func (cq *CollectionQuery) SortByCount(expression interface{}) *CollectionQuery {
+	c := *cq
+
+	c.pipes = append(c.pipes[:len(c.pipes):len(c.pipes)], &bson.M{
+		"$sortByCount": expression,
+	})
+
+	return &c
+}
+
+// Unwind outputs a document for every element of the array in field, with the field set to
+// that element. If includeArrayIndex is not empty the index of the element is stored in a field
+// with that name. If preserveNullAndEmpty is true documents where the array is missing, null or
+// empty are output unchanged instead of being removed
+func (cq *CollectionQuery) Unwind(field string, includeArrayIndex string, preserveNullAndEmpty bool) *CollectionQuery {
+	c := *cq
+
+	unwind := bson.M{
+		"path":                       "$" + field,
+		"preserveNullAndEmptyArrays": preserveNullAndEmpty,
+	}
+
+	if includeArrayIndex != "" {
+		unwind["includeArrayIndex"] = includeArrayIndex
+	}
+
+	c.pipes = append(c.pipes[:len(c.pipes):len(c.pipes)], &bson.M{
+		"$unwind": unwind,
+	})
+
+	return &c
+}
+
+// ReplaceRoot replaces every document with the document the expression evaluates to
+func (cq *CollectionQuery) ReplaceRoot(expression interface{}) *CollectionQuery {
+	c := *cq
+
+	c.pipes = append(c.pipes[:len(c.pipes):len(c.pipes)], &bson.M{
+		"$replaceRoot": bson.M{
+			"newRoot": expression,
+		},
+	})
+
+	return &c
+}
+
+// ReplaceWith replaces every document with the document the expression evaluates to (MongoDB 4.2+)
+func (cq *CollectionQuery) ReplaceWith(expression interface{}) *CollectionQuery {
+	c := *cq
+
+	c.pipes = append(c.pipes[:len(c.pipes):len(c.pipes)], &bson.M{
+		"$replaceWith": expression,
+	})
+
+	return &c
+}
+
+// Facet runs multiple queries on the same documents in a single pass and returns a single document
+// with the results of every query as an array under its name. The queries must be built from
+// the same collection, for example with All()
+func (cq *CollectionQuery) Facet(facets map[string]*CollectionQuery) *CollectionQuery {
+	c := *cq
+
+	facet := bson.M{}
+
+	for name, query := range facets {
+		if query.err != nil && c.err == nil {
+			c.err = query.err
+		}
+
+		if !sameCollection(query.Collection, cq.Collection) && c.err == nil {
+			c.err = fmt.Errorf("wrap: facet %s is a query on collection %s, expected collection %s", name, query.Collection.ID, cq.Collection.ID)
+		}
+
+		facet[name] = query.pipes
+	}
+
+	c.pipes = append(c.pipes[:len(c.pipes):len(c.pipes)], &bson.M{
+		"$facet": facet,
+	})
+
+	return &c
+}
+
 // DocumentIterator gives you an iterator to loop over the documents
 func (cq *CollectionQuery) DocumentIterator() (*Iterator, error) {
-	cursor, err := cq.Collection.collection.Aggregate(cq.Collection.Database.Client.ctx(), cq.pipes)
+	if cq.err != nil {
+		return nil, cq.err
+	}
+
+	ctx, cancel := cq.Collection.Database.Client.ctx()
+	defer cancel()
+
+	cursor, err := cq.Collection.collection.Aggregate(ctx, cq.pipes)
 	if err != nil {
-		return nil, err
+		return nil, convertError(err)
 	}
 
 	return &Iterator{
@@ -133,3 +440,79 @@ func (cq *CollectionQuery) DocumentIterator() (*Iterator, error) {
 		cursor:     cursor,
 	}, nil
 }
+
+// OutTo runs the query and replaces all documents in the target collection with the resulting documents.
+// Writing to a collection in another database requires MongoDB 4.4
+func (cq *CollectionQuery) OutTo(target *Collection) (*OutputResult, error) {
+	var out interface{} = target.ID
+
+	if target.Database.ID != cq.Collection.Database.ID {
+		out = bson.M{
+			"db":   target.Database.ID,
+			"coll": target.ID,
+		}
+	}
+
+	return cq.output(target, &bson.M{
+		"$out": out,
+	})
+}
+
+// MergeInto runs the query and merges the resulting documents into the target collection. Documents
+// are matched on the fields in on, which need a unique index in the target collection. If on is empty
+// documents are matched on _id. An empty whenMatched or whenNotMatched uses the server default ("merge"
+// and "insert"). MergeInto requires MongoDB 4.2
+func (cq *CollectionQuery) MergeInto(target *Collection, on []string, whenMatched WhenMatched, whenNotMatched WhenNotMatched) (*OutputResult, error) {
+	merge := bson.M{
+		"into": bson.M{
+			"db":   target.Database.ID,
+			"coll": target.ID,
+		},
+	}
+
+	if whenMatched != "" {
+		merge["whenMatched"] = whenMatched
+	}
+
+	if whenNotMatched != "" {
+		merge["whenNotMatched"] = whenNotMatched
+	}
+
+	if len(on) > 0 {
+		merge["on"] = on
+	}
+
+	return cq.output(target, &bson.M{
+		"$merge": merge,
+	})
+}
+
+func (cq *CollectionQuery) output(target *Collection, stage *bson.M) (*OutputResult, error) {
+	if cq.err != nil {
+		return nil, cq.err
+	}
+
+	ctx, cancel := cq.Collection.Database.Client.ctx()
+	defer cancel()
+
+	start := time.Now()
+
+	cursor, err := cq.Collection.collection.Aggregate(ctx, append(cq.pipes[:len(cq.pipes):len(cq.pipes)], stage))
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	err = cursor.Close(ctx)
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	return &OutputResult{
+		Collection: target,
+		Duration:   time.Since(start),
+	}, nil
+}
+
+func sameCollection(a *Collection, b *Collection) bool {
+	return a.ID == b.ID && a.Database.ID == b.Database.ID
+}
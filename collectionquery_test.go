@@ -0,0 +1,473 @@
+package wrap_test
+
+import (
+	"testing"
+
+	"github.com/lucacasonato/wrap"
+	"github.com/lucacasonato/wrap/accumulators"
+	"github.com/lucacasonato/wrap/expressions"
+	"github.com/lucacasonato/wrap/filter"
+)
+
+// Fish record
+type Fish struct {
+	Name   string `bson:"name"`
+	Color  string `bson:"color"`
+	Length int    `bson:"length"`
+}
+
+// FishGroup is the result of grouping fish by color
+type FishGroup struct {
+	Color  string   `bson:"_id"`
+	Count  int      `bson:"count"`
+	Length int      `bson:"length"`
+	Names  []string `bson:"names"`
+}
+
+func createFish() (*wrap.Collection, error) {
+	collection, err := createCollection()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = collection.Bulk(func(c *wrap.BulkCollection) error {
+		for _, fish := range []Fish{
+			{Name: "nemo", Color: "orange", Length: 10},
+			{Name: "marlin", Color: "orange", Length: 12},
+			{Name: "dory", Color: "blue", Length: 30},
+			{Name: "bruce", Color: "grey", Length: 500},
+		} {
+			err := c.Add(fish)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+func TestCollectionQueryGroup(t *testing.T) {
+	collection, err := createFish()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iterator, err := collection.All().
+		Group(expressions.Value("color"), map[string]accumulators.Accumulator{
+			"count":  accumulators.Count(),
+			"length": accumulators.Sum(expressions.Value("length")),
+			"names":  accumulators.AddToSet(expressions.Value("name")),
+		}).
+		Sort(wrap.Ascending("_id")).
+		DocumentIterator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iterator.Close()
+
+	groups := []FishGroup{}
+
+	for iterator.Next() {
+		group := FishGroup{}
+
+		err := iterator.DataTo(&group)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		groups = append(groups, group)
+	}
+
+	if len(groups) != 3 || groups[2].Color != "orange" || groups[2].Count != 2 || groups[2].Length != 22 {
+		t.Fatalf("unexpected groups %+v", groups)
+	}
+
+	err = collection.Database.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectionQueryUnwindFacet(t *testing.T) {
+	collection, err := createFish()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iterator, err := collection.All().
+		Group(expressions.Value("color"), map[string]accumulators.Accumulator{
+			"fish": accumulators.Push(expressions.Value("$ROOT")),
+		}).
+		Unwind("fish", "index", false).
+		ReplaceRoot(expressions.Value("fish")).
+		Facet(map[string]*wrap.CollectionQuery{
+			"longest": collection.All().Sort(wrap.Descending("length")).Limit(1),
+			"total":   collection.All().Count("count"),
+		}).
+		DocumentIterator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iterator.Close()
+
+	if !iterator.Next() {
+		t.Fatal(iterator.Err())
+	}
+
+	result := struct {
+		Longest []Fish `bson:"longest"`
+		Total   []struct {
+			Count int `bson:"count"`
+		} `bson:"total"`
+	}{}
+
+	err = iterator.DataTo(&result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Longest) != 1 || result.Longest[0].Name != "bruce" || result.Total[0].Count != 4 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	err = collection.Database.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectionQueryJoinQuery(t *testing.T) {
+	collection, err := createFish()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	colors := collection.Database.Collection("colors")
+
+	for _, color := range []string{"orange", "blue"} {
+		_, err = colors.Add(map[string]interface{}{
+			"color": color,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	iterator, err := colors.All().
+		JoinQuery(
+			collection.
+				Where(filter.Expression(expressions.Equals(expressions.Value("color"), expressions.Variable("color")))).
+				Sort(wrap.Descending("length")).
+				Limit(1),
+			map[string]interface{}{
+				"color": expressions.Value("color"),
+			},
+			"longest",
+		).
+		Sort(wrap.Ascending("color")).
+		DocumentIterator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iterator.Close()
+
+	longest := []string{}
+
+	for iterator.Next() {
+		color := struct {
+			Longest []Fish `bson:"longest"`
+		}{}
+
+		err := iterator.DataTo(&color)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, fish := range color.Longest {
+			longest = append(longest, fish.Name)
+		}
+	}
+
+	if len(longest) != 2 || longest[0] != "dory" || longest[1] != "marlin" {
+		t.Fatalf("unexpected longest fish %v", longest)
+	}
+
+	err = collection.Database.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectionQueryGraphJoin(t *testing.T) {
+	collection, err := createCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	employees := collection.Database.Collection("employees")
+
+	for _, employee := range []map[string]interface{}{
+		{"name": "ceo"},
+		{"name": "cto", "boss": "ceo"},
+		{"name": "developer", "boss": "cto"},
+		{"name": "intern", "boss": "developer", "fired": true},
+	} {
+		_, err = employees.Add(employee)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	iterator, err := employees.
+		Where(filter.Equal("name", "intern")).
+		GraphJoin(employees, expressions.Value("boss"), "boss", "name", "bosses", -1, "level", filter.NotEqual("name", "ceo")).
+		DocumentIterator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iterator.Close()
+
+	if !iterator.Next() {
+		t.Fatal(iterator.Err())
+	}
+
+	intern := struct {
+		Bosses []struct {
+			Name  string `bson:"name"`
+			Level int    `bson:"level"`
+		} `bson:"bosses"`
+	}{}
+
+	err = iterator.DataTo(&intern)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(intern.Bosses) != 2 {
+		t.Fatalf("expected 2 bosses, got %+v", intern.Bosses)
+	}
+
+	err = collection.Database.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectionQueryBucket(t *testing.T) {
+	collection, err := createFish()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iterator, err := collection.All().
+		Bucket(expressions.Value("length"), []interface{}{0, 20, 100}, "huge", map[string]accumulators.Accumulator{
+			"count": accumulators.Count(),
+			"names": accumulators.Push(expressions.Value("name")),
+		}).
+		DocumentIterator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iterator.Close()
+
+	counts := map[interface{}]int{}
+
+	for iterator.Next() {
+		bucket := struct {
+			ID    interface{} `bson:"_id"`
+			Count int         `bson:"count"`
+		}{}
+
+		err := iterator.DataTo(&bucket)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		counts[bucket.ID] = bucket.Count
+	}
+
+	if counts[int32(0)] != 2 || counts[int32(20)] != 1 || counts["huge"] != 1 {
+		t.Fatalf("unexpected buckets %v", counts)
+	}
+
+	iterator, err = collection.All().
+		BucketAuto(expressions.Value("length"), 2, wrap.PowersOf2, nil).
+		DocumentIterator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iterator.Close()
+
+	for iterator.Next() {
+	}
+
+	if iterator.Err() != nil {
+		t.Fatal(iterator.Err())
+	}
+
+	iterator, err = collection.All().
+		SortByCount(expressions.Value("color")).
+		DocumentIterator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iterator.Close()
+
+	if !iterator.Next() {
+		t.Fatal(iterator.Err())
+	}
+
+	top := FishGroup{}
+
+	err = iterator.DataTo(&top)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if top.Color != "orange" || top.Count != 2 {
+		t.Fatalf("unexpected top color %+v", top)
+	}
+
+	err = collection.Database.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectionQueryOutMerge(t *testing.T) {
+	collection, err := createFish()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	colors := collection.Database.Collection("colors")
+
+	res, err := collection.All().
+		SortByCount(expressions.Value("color")).
+		OutTo(colors)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Collection != colors {
+		t.Fatalf("unexpected output collection %v", res.Collection)
+	}
+
+	_, err = collection.All().
+		Group(expressions.Value("color"), map[string]accumulators.Accumulator{
+			"length": accumulators.Sum(expressions.Value("length")),
+		}).
+		MergeInto(colors, nil, wrap.WhenMatchedMerge, wrap.WhenNotMatchedInsert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the server defaults are used if the actions are empty
+	_, err = collection.All().
+		Group(expressions.Value("color"), map[string]accumulators.Accumulator{
+			"length": accumulators.Sum(expressions.Value("length")),
+		}).
+		MergeInto(colors, nil, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := colors.Document(wrap.StringID("orange")).Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orange := FishGroup{}
+
+	err = data.DataTo(&orange)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if orange.Count != 2 || orange.Length != 22 {
+		t.Fatalf("unexpected merged document %+v", orange)
+	}
+
+	err = collection.Database.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectionQueryBranches(t *testing.T) {
+	collection, err := createFish()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := collection.All().Sort(wrap.Ascending("length")).Skip(0).Limit(10)
+
+	first := base.Limit(1)
+	last := base.Skip(3)
+
+	for expected, query := range map[string]*wrap.CollectionQuery{
+		"nemo":  first,
+		"bruce": last,
+	} {
+		iterator, err := query.DocumentIterator()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names := []string{}
+
+		for iterator.Next() {
+			fish := Fish{}
+
+			err := iterator.DataTo(&fish)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			names = append(names, fish.Name)
+		}
+
+		iterator.Close()
+
+		if len(names) != 1 || names[0] != expected {
+			t.Fatalf("expected %s, got %v", expected, names)
+		}
+	}
+
+	err = collection.Database.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectionQueryOtherCollection(t *testing.T) {
+	collection, err := createCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := collection.Database.Client.Database("other").Collection("fish")
+
+	_, err = collection.All().Facet(map[string]*wrap.CollectionQuery{
+		"other": other.All(),
+	}).DocumentIterator()
+	if err == nil {
+		t.Fatal("expected an error for a facet on another collection")
+	}
+
+	_, err = collection.All().JoinQuery(other.All(), nil, "other").DocumentIterator()
+	if err == nil {
+		t.Fatal("expected an error for a join query on another database")
+	}
+
+	_, err = collection.All().GraphJoin(other, expressions.Value("boss"), "boss", "name", "bosses", -1, "", nil).DocumentIterator()
+	if err == nil {
+		t.Fatal("expected an error for a graph join on another database")
+	}
+}
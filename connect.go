@@ -9,17 +9,29 @@ import (
 )
 
 // Connect to a mongo instance
-func Connect(mongoURI string, timeout time.Duration) (*Client, error) {
-	ctx, _ := context.WithTimeout(context.Background(), timeout)
+func Connect(mongoURI string, timeout time.Duration, opts ...ConnectOption) (*Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURI))
+	clientOptions := options.Client().ApplyURI(mongoURI)
+	for _, opt := range opts {
+		err := opt(clientOptions)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
-		return nil, err
+		return nil, convertError(err)
 	}
 
 	err = client.Ping(ctx, nil)
 	if err != nil {
-		return nil, err
+		// stop the connection pool and monitoring of the client that is not returned
+		client.Disconnect(context.Background())
+
+		return nil, convertError(err)
 	}
 
 	return &Client{
@@ -28,3 +40,31 @@ func Connect(mongoURI string, timeout time.Duration) (*Client, error) {
 		timeout: timeout,
 	}, nil
 }
+
+// Disconnect closes all connections to the server. The client can not be used after this
+func (c *Client) Disconnect() error {
+	ctx, cancel := c.ctx()
+	defer cancel()
+
+	err := c.client.Disconnect(ctx)
+	if err != nil {
+		return convertError(err)
+	}
+
+	return nil
+}
+
+// Ping checks if the server is reachable and returns the round trip time
+func (c *Client) Ping() (time.Duration, error) {
+	ctx, cancel := c.ctx()
+	defer cancel()
+
+	start := time.Now()
+
+	err := c.client.Ping(ctx, nil)
+	if err != nil {
+		return 0, convertError(err)
+	}
+
+	return time.Since(start), nil
+}
@@ -27,4 +27,64 @@ func TestConnectFail(t *testing.T) {
 	}
 
 	t.Log(client)
-}
\ No newline at end of file
+}
+
+func TestConnectOptions(t *testing.T) {
+	journal := true
+
+	client, err := wrap.Connect("mongodb://localhost:27017", 2*time.Second,
+		wrap.AppName("wrap-test"),
+		wrap.PoolSize(1, 10),
+		wrap.RetryWrites(false),
+		wrap.RetryReads(true),
+		wrap.DefaultReadPreference(wrap.PrimaryPreferred),
+		wrap.DefaultReadConcern(wrap.ReadConcernLocal),
+		wrap.DefaultWriteConcern(wrap.WriteConcern{W: 1, Journal: &journal, Timeout: time.Second}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.Disconnect()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConnectOptionsInvalid(t *testing.T) {
+	_, err := wrap.Connect("mongodb://localhost:27017", 2*time.Second, wrap.DefaultReadPreference("everywhere"))
+	if err == nil {
+		t.Fatal("connect did not error on an invalid read preference")
+	}
+}
+
+func TestPing(t *testing.T) {
+	client, err := connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rtt, err := client.Ping()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log(rtt)
+}
+
+func TestDisconnect(t *testing.T) {
+	client, err := connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.Disconnect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Ping()
+	if err == nil {
+		t.Fatal("ping did not error on a disconnected client")
+	}
+}
@@ -0,0 +1,199 @@
+package wrap
+
+import (
+	"crypto/tls"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// ConnectOption configures the connection made by Connect. Options are applied
+// after the connection string, so they override values set in the URI
+type ConnectOption func(opts *options.ClientOptions) error
+
+// Credential used to authenticate with the server
+type Credential struct {
+	// Mechanism is the authentication mechanism, for example "SCRAM-SHA-256". The server default is used if empty
+	Mechanism string
+	// Source is the database to authenticate against. "admin" is used if empty
+	Source   string
+	Username string
+	Password string
+}
+
+// ReadPreference determines which members of a replica set reads are sent to
+type ReadPreference string
+
+const (
+	// Primary reads only from the primary
+	Primary ReadPreference = "primary"
+	// PrimaryPreferred reads from the primary if it is available and from a secondary otherwise
+	PrimaryPreferred ReadPreference = "primaryPreferred"
+	// Secondary reads only from secondaries
+	Secondary ReadPreference = "secondary"
+	// SecondaryPreferred reads from a secondary if one is available and from the primary otherwise
+	SecondaryPreferred ReadPreference = "secondaryPreferred"
+	// Nearest reads from the member with the lowest latency
+	Nearest ReadPreference = "nearest"
+)
+
+// ReadConcern is the level of isolation of reads
+type ReadConcern string
+
+const (
+	// ReadConcernLocal returns the most recent data on the queried member
+	ReadConcernLocal ReadConcern = "local"
+	// ReadConcernAvailable returns the most recent data on the queried member, even for orphaned documents on shards
+	ReadConcernAvailable ReadConcern = "available"
+	// ReadConcernMajority returns data acknowledged by a majority of the replica set
+	ReadConcernMajority ReadConcern = "majority"
+	// ReadConcernLinearizable returns data that reflects all successful majority acknowledged writes before the read
+	ReadConcernLinearizable ReadConcern = "linearizable"
+	// ReadConcernSnapshot returns data from a snapshot of majority committed data
+	ReadConcernSnapshot ReadConcern = "snapshot"
+)
+
+// WriteConcern is the level of acknowledgement requested for writes
+type WriteConcern struct {
+	// W is the amount of members that have to acknowledge the write. 0 uses the server default. Ignored if Majority is true
+	W int
+	// Majority requires a majority of the members to acknowledge the write
+	Majority bool
+	// Journal requires the write to be written to the on-disk journal if true. nil uses the server default
+	Journal *bool
+	// Timeout is the time after which a write fails if it has not been acknowledged. 0 means no timeout
+	Timeout time.Duration
+}
+
+// Auth authenticates with the specified credential
+func Auth(credential Credential) ConnectOption {
+	return func(opts *options.ClientOptions) error {
+		opts.SetAuth(options.Credential{
+			AuthMechanism: credential.Mechanism,
+			AuthSource:    credential.Source,
+			Username:      credential.Username,
+			Password:      credential.Password,
+			PasswordSet:   credential.Password != "",
+		})
+
+		return nil
+	}
+}
+
+// TLS connects using TLS with the specified config. The config can contain
+// custom root CAs and client certificates
+func TLS(config *tls.Config) ConnectOption {
+	return func(opts *options.ClientOptions) error {
+		opts.SetTLSConfig(config)
+
+		return nil
+	}
+}
+
+// PoolSize sets the minimum and maximum amount of connections in the pool of every server
+func PoolSize(min uint64, max uint64) ConnectOption {
+	return func(opts *options.ClientOptions) error {
+		opts.SetMinPoolSize(min)
+		opts.SetMaxPoolSize(max)
+
+		return nil
+	}
+}
+
+// AppName sets the name of the application that is sent to the server
+func AppName(name string) ConnectOption {
+	return func(opts *options.ClientOptions) error {
+		opts.SetAppName(name)
+
+		return nil
+	}
+}
+
+// RetryWrites retries supported writes once on network errors
+func RetryWrites(retry bool) ConnectOption {
+	return func(opts *options.ClientOptions) error {
+		opts.SetRetryWrites(retry)
+
+		return nil
+	}
+}
+
+// RetryReads retries supported reads once on network errors
+func RetryReads(retry bool) ConnectOption {
+	return func(opts *options.ClientOptions) error {
+		opts.RetryReads = &retry
+
+		return nil
+	}
+}
+
+// Compressors sets the compressors to use for communication with the server in order of preference.
+// Supported are "snappy" and "zlib"
+func Compressors(compressors ...string) ConnectOption {
+	return func(opts *options.ClientOptions) error {
+		opts.SetCompressors(compressors)
+
+		return nil
+	}
+}
+
+// DefaultReadPreference sets the read preference used for all reads
+func DefaultReadPreference(preference ReadPreference) ConnectOption {
+	return func(opts *options.ClientOptions) error {
+		mode, err := readpref.ModeFromString(string(preference))
+		if err != nil {
+			return err
+		}
+
+		rp, err := readpref.New(mode)
+		if err != nil {
+			return err
+		}
+
+		opts.SetReadPreference(rp)
+
+		return nil
+	}
+}
+
+// DefaultReadConcern sets the read concern used for all reads
+func DefaultReadConcern(concern ReadConcern) ConnectOption {
+	return func(opts *options.ClientOptions) error {
+		opts.SetReadConcern(readconcern.New(readconcern.Level(string(concern))))
+
+		return nil
+	}
+}
+
+// DefaultWriteConcern sets the write concern used for all writes
+func DefaultWriteConcern(concern WriteConcern) ConnectOption {
+	return func(opts *options.ClientOptions) error {
+		wcOptions := []writeconcern.Option{}
+
+		if concern.Journal != nil {
+			wcOptions = append(wcOptions, writeconcern.J(*concern.Journal))
+		}
+
+		if concern.Timeout > 0 {
+			wcOptions = append(wcOptions, writeconcern.WTimeout(concern.Timeout))
+		}
+
+		if concern.Majority {
+			wcOptions = append(wcOptions, writeconcern.WMajority())
+		} else if concern.W > 0 {
+			wcOptions = append(wcOptions, writeconcern.W(concern.W))
+		}
+
+		// an empty write concern is rejected by the driver, keep the server default
+		if len(wcOptions) == 0 {
+			return nil
+		}
+
+		opts.SetWriteConcern(writeconcern.New(wcOptions...))
+
+		return nil
+	}
+}
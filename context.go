@@ -0,0 +1,59 @@
+package wrap
+
+import (
+	"context"
+)
+
+// WithContext returns a copy of the client that runs all operations in the
+// specified context. The client timeout is still applied to every operation.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	client := *c
+	client.context = ctx
+
+	return &client
+}
+
+// Context returns the context operations on the client are run in
+func (c *Client) Context() context.Context {
+	return c.context
+}
+
+// WithContext returns a copy of the database that runs all operations in the specified context
+func (db *Database) WithContext(ctx context.Context) *Database {
+	newDB := *db
+	newDB.Client = db.Client.WithContext(ctx)
+
+	return &newDB
+}
+
+// WithContext returns a copy of the collection that runs all operations in the specified context
+func (c *Collection) WithContext(ctx context.Context) *Collection {
+	newCollection := *c
+	newCollection.Database = c.Database.WithContext(ctx)
+
+	return &newCollection
+}
+
+// WithContext returns a copy of the collection query that runs all operations in the specified context
+func (cq *CollectionQuery) WithContext(ctx context.Context) *CollectionQuery {
+	newCollectionQuery := *cq
+	newCollectionQuery.Collection = cq.Collection.WithContext(ctx)
+
+	return &newCollectionQuery
+}
+
+// WithContext returns a copy of the document that runs all operations in the specified context
+func (d *Document) WithContext(ctx context.Context) *Document {
+	newDocument := *d
+	newDocument.Collection = d.Collection.WithContext(ctx)
+
+	return &newDocument
+}
+
+// WithContext returns a copy of the iterator that runs all further operations in the specified context
+func (i *Iterator) WithContext(ctx context.Context) *Iterator {
+	newIterator := *i
+	newIterator.Collection = i.Collection.WithContext(ctx)
+
+	return &newIterator
+}
@@ -0,0 +1,38 @@
+package wrap_test
+
+import (
+	"context"
+	"testing"
+)
+
+func TestContext(t *testing.T) {
+	collection, err := createCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	fish := collection.WithContext(ctx)
+
+	_, err = fish.Add(map[string]interface{}{
+		"name": "the green fish",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+
+	_, err = fish.Add(map[string]interface{}{
+		"name": "the yellow fish",
+	})
+	if err == nil {
+		t.Fatal("add did not error on a canceled context")
+	}
+
+	err = collection.Database.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
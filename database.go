@@ -1,5 +1,9 @@
 package wrap
 
+import (
+	"go.mongodb.org/mongo-driver/bson"
+)
+
 // Database gets a database instance from a client
 func (c *Client) Database(id string) *Database {
 	database := c.client.Database(id)
@@ -9,5 +13,30 @@ func (c *Client) Database(id string) *Database {
 
 // Delete a database
 func (d *Database) Delete() error {
-	return d.database.Drop(d.Client.ctx())
+	ctx, cancel := d.Client.ctx()
+	defer cancel()
+
+	err := d.database.Drop(ctx)
+	if err != nil {
+		return convertError(err)
+	}
+
+	return nil
+}
+
+// CreateCollection creates a collection with options, for example a validator. Collections
+// without options are created automatically when data is first added
+func (d *Database) CreateCollection(id string, opts ...CollectionOption) (*Collection, error) {
+	command := bson.D{{Key: "create", Value: id}}
+	command = append(command, collectionOptions(opts)...)
+
+	ctx, cancel := d.Client.ctx()
+	defer cancel()
+
+	err := d.database.RunCommand(ctx, command).Err()
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	return d.Collection(id), nil
 }
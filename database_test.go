@@ -1,9 +1,12 @@
 package wrap_test
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/lucacasonato/wrap"
+	"github.com/lucacasonato/wrap/filter"
+	"github.com/lucacasonato/wrap/types"
 )
 
 func createDatabase() (*wrap.Database, error) {
@@ -37,3 +40,64 @@ func TestDatabaseDelete(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestDatabaseCreateCollection(t *testing.T) {
+	database, err := createDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema := filter.NewSchema(types.Object).
+		Required("name").
+		Property("name", filter.NewSchema(types.String).MinLength(1)).
+		Property("weight", filter.NewSchema(types.Double, types.Int).Minimum(0))
+
+	validated, err := database.CreateCollection("validated",
+		wrap.Validator(filter.Schema(schema)),
+		wrap.Validation(wrap.ValidationStrict, wrap.ValidationError),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = validated.Add(map[string]interface{}{"name": "the blue fish", "weight": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = validated.Add(map[string]interface{}{"weight": -1})
+	if !errors.Is(err, wrap.ErrValidation) {
+		t.Fatalf("expected ErrValidation, got %v", err)
+	}
+
+	iterator, err := validated.Where(filter.Schema(schema)).DocumentIterator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := 0
+	for iterator.Next() {
+		count++
+	}
+
+	iterator.Close()
+
+	if count != 1 {
+		t.Fatalf("expected 1 valid document, got %d", count)
+	}
+
+	err = validated.Modify(wrap.Validation(wrap.ValidationModerate, wrap.ValidationWarn))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = validated.Add(map[string]interface{}{"weight": -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = validated.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
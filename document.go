@@ -1,47 +1,46 @@
 package wrap
 
 import (
-	"github.com/imdario/mergo"
 	"github.com/lucacasonato/wrap/update"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
 )
 
-type dataInterface interface{}
-
-type documentData struct {
-	dataInterface
-	ID primitive.ObjectID `bson:"_id"`
-}
-
 // Document get a single document from a collection
-func (c *Collection) Document(id string) *Document {
+func (c *Collection) Document(id ID) *Document {
 	return &Document{ID: id, Collection: c}
 }
 
 // Add a document with a certain value
 func (c *Collection) Add(data interface{}) (*Document, error) {
-	res, err := c.collection.InsertOne(c.Database.Client.ctx(), data)
+	ctx, cancel := c.Database.Client.ctx()
+	defer cancel()
+
+	res, err := c.collection.InsertOne(ctx, data)
 	if err != nil {
-		return nil, err
+		return nil, convertError(err)
 	}
 
-	return &Document{ID: res.InsertedID.(primitive.ObjectID).Hex(), Collection: c}, nil
+	return c.documentFromID(res.InsertedID)
 }
 
 // Get the contents of a document
 func (d *Document) Get() (*DocumentData, error) {
-	objID, err := primitive.ObjectIDFromHex(d.ID)
+	filter, err := idFilter(d.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	result := d.Collection.collection.FindOne(d.Collection.Database.Client.ctx(), bson.M{"_id": objID})
-	err = result.Err()
+	ctx, cancel := d.Collection.Database.Client.ctx()
+	defer cancel()
+
+	result := d.Collection.collection.FindOne(ctx, filter)
+	_, err = result.DecodeBytes()
 	if err != nil {
-		return nil, err
+		return nil, convertError(err)
 	}
 
 	return &DocumentData{
@@ -56,7 +55,7 @@ func (d *DocumentData) Data() (interface{}, error) {
 
 	err := d.result.Decode(&data)
 	if err != nil {
-		return nil, err
+		return nil, convertError(err)
 	}
 
 	return data, nil
@@ -66,62 +65,105 @@ func (d *DocumentData) Data() (interface{}, error) {
 func (d *DocumentData) DataTo(data interface{}) error {
 	err := d.result.Decode(data)
 	if err != nil {
-		return err
+		return convertError(err)
 	}
 
 	return nil
 }
 
 // Set a document to a certain value
-func (d *Document) Set(data interface{}) error {
-	objID, err := primitive.ObjectIDFromHex(d.ID)
+func (d *Document) Set(data interface{}) (*UpdateResult, error) {
+	filter, err := idFilter(d.ID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	_, err = d.Collection.collection.ReplaceOne(d.Collection.Database.Client.ctx(), bson.M{"_id": objID}, data, options.Replace().SetUpsert(true))
+	ctx, cancel := d.Collection.Database.Client.ctx()
+	defer cancel()
+
+	res, err := d.Collection.collection.ReplaceOne(ctx, filter, data, options.Replace().SetUpsert(true))
 	if err != nil {
-		return err
+		return nil, convertError(err)
 	}
 
-	return nil
+	return d.Collection.updateResult(res)
 }
 
 // Update a document using the update operators
-func (d *Document) Update(upsert bool, updates ...update.Update) error {
-	objID, err := primitive.ObjectIDFromHex(d.ID)
+func (d *Document) Update(upsert bool, updates ...update.Update) (*UpdateResult, error) {
+	filter, err := idFilter(d.ID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	var final = bson.M{}
-
-	for _, update := range updates {
-		err := mergo.Merge(&final, update)
-		if err != nil {
-			return err
-		}
+	final, err := update.Combine(updates...)
+	if err != nil {
+		return nil, err
 	}
 
-	_, err = d.Collection.collection.UpdateOne(d.Collection.Database.Client.ctx(), bson.M{"_id": objID}, final, options.Update().SetUpsert(upsert))
+	ctx, cancel := d.Collection.Database.Client.ctx()
+	defer cancel()
+
+	res, err := d.Collection.collection.UpdateOne(ctx, filter, final, updateOptions(upsert, updates))
 	if err != nil {
-		return err
+		return nil, convertError(err)
 	}
 
-	return nil
+	return d.Collection.updateResult(res)
 }
 
 // Delete a document from a collection
-func (d *Document) Delete() error {
-	objID, err := primitive.ObjectIDFromHex(d.ID)
+func (d *Document) Delete() (*DeleteResult, error) {
+	filter, err := idFilter(d.ID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	_, err = d.Collection.collection.DeleteOne(d.Collection.Database.Client.ctx(), bson.M{"_id": objID})
+	ctx, cancel := d.Collection.Database.Client.ctx()
+	defer cancel()
+
+	res, err := d.Collection.collection.DeleteOne(ctx, filter)
 	if err != nil {
-		return err
+		return nil, convertError(err)
 	}
 
-	return nil
+	return deleteResult(res), nil
+}
+
+// documentFromID returns the document with the id returned by the server. Returns ErrInvalidID if the id can not be converted
+func (c *Collection) documentFromID(value interface{}) (*Document, error) {
+	id, err := idFromValue(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Document(id), nil
+}
+
+// ensureID returns the encoded document with an _id field and the value of that field
+func ensureID(data interface{}) (bson.Raw, interface{}, error) {
+	raw, err := bson.Marshal(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var doc bson.D
+
+	err = bson.Unmarshal(raw, &doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, e := range doc {
+		if e.Key == "_id" {
+			return raw, e.Value, nil
+		}
+	}
+
+	id := primitive.NewObjectID()
+
+	// insert the _id element before the elements of the encoded document
+	elements := raw[4 : len(raw)-1]
+
+	return bsoncore.BuildDocument(nil, bsoncore.AppendObjectIDElement(nil, "_id", id), elements), id, nil
 }
@@ -1,8 +1,11 @@
 package wrap_test
 
 import (
+	"reflect"
 	"testing"
 
+	"github.com/lucacasonato/wrap"
+	"github.com/lucacasonato/wrap/filter"
 	"github.com/lucacasonato/wrap/update"
 )
 
@@ -21,11 +24,15 @@ func TestCollectionAddUpdate(t *testing.T) {
 
 	t.Log(doc)
 
-	err = doc.Update(true, update.Set("name", "The red fish."))
+	res, err := doc.Update(true, update.Set("name", "The red fish."))
 	if err != nil {
 		t.Fatal(err)
 	}
 
+	if res.Matched != 1 || res.Modified != 1 || res.Upserted != nil {
+		t.Fatalf("unexpected update result %+v", res)
+	}
+
 	err = collection.Database.Delete()
 	if err != nil {
 		t.Fatal(err)
@@ -38,15 +45,24 @@ func TestDocumentSetGet(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	redFish := collection.Document("0123456789abcdef01234567")
+	id, err := wrap.ObjectIDFromHex("0123456789abcdef01234567")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redFish := collection.Document(id)
 
-	err = redFish.Set(map[string]interface{}{
+	res, err := redFish.Set(map[string]interface{}{
 		"name": "the red fish 2",
 	})
 	if err != nil {
 		t.Fatal(err)
 	}
 
+	if res.Upserted == nil || !res.Upserted.ID.Equal(redFish.ID) {
+		t.Fatalf("unexpected set result %+v", res)
+	}
+
 	var fishData map[string]interface{}
 
 	doc, err := redFish.Get()
@@ -66,3 +82,281 @@ func TestDocumentSetGet(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestDocumentDelete(t *testing.T) {
+	collection, err := createCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := collection.Add(map[string]interface{}{
+		"name": "the orange fish",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := doc.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Deleted != 1 {
+		t.Fatalf("expected 1 deleted document, got %d", res.Deleted)
+	}
+
+	res, err = doc.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Deleted != 0 {
+		t.Fatalf("expected 0 deleted documents, got %d", res.Deleted)
+	}
+
+	err = collection.Database.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Aquarium with arrays of values and documents
+type Aquarium struct {
+	Temperatures []int  `bson:"temperatures"`
+	Fish         []Fish `bson:"fish"`
+}
+
+func TestDocumentPushArrayMatch(t *testing.T) {
+	collection, err := createCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := collection.Add(Aquarium{
+		Temperatures: []int{20, 24},
+		Fish:         []Fish{{Name: "nemo", Length: 2}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = doc.Update(false,
+		update.Push("temperatures", []interface{}{18, 30, 26}, update.SortValues(true), update.Slice(-4)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = doc.Update(false,
+		update.Push("fish", []interface{}{Fish{Name: "dory", Length: 3}}, update.Position(0)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := doc.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aquarium := Aquarium{}
+
+	err = data.DataTo(&aquarium)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(aquarium.Temperatures, []int{20, 24, 26, 30}) {
+		t.Fatalf("unexpected temperatures %v", aquarium.Temperatures)
+	}
+
+	if len(aquarium.Fish) != 2 || aquarium.Fish[0].Name != "dory" {
+		t.Fatalf("unexpected fish %v", aquarium.Fish)
+	}
+
+	for _, test := range []struct {
+		filter   filter.Filter
+		expected int
+	}{
+		{filter.ArraySingleMatch("temperatures", filter.GreaterThan("", 25), filter.LessThan("", 28)), 1},
+		{filter.ArraySingleMatch("temperatures", filter.GreaterThan("", 30)), 0},
+		{filter.ArraySingleMatch("fish", filter.Equal("name", "dory"), filter.GreaterThan("length", 2)), 1},
+		{filter.ArraySingleMatch("fish", filter.Equal("name", "nemo"), filter.GreaterThan("length", 2)), 0},
+	} {
+		iterator, err := collection.Where(filter.AND(filter.Equal("_id", doc.ID), test.filter)).DocumentIterator()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		count := 0
+		for iterator.Next() {
+			count++
+		}
+
+		iterator.Close()
+
+		if count != test.expected {
+			t.Fatalf("expected %d documents, got %d", test.expected, count)
+		}
+	}
+
+	err = collection.Database.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Order with line items
+type Order struct {
+	Items []LineItem `bson:"items"`
+}
+
+// LineItem of an order
+type LineItem struct {
+	SKU      string `bson:"sku"`
+	Quantity int    `bson:"quantity"`
+	Shipped  bool   `bson:"shipped"`
+}
+
+func TestDocumentUpdateArrayItems(t *testing.T) {
+	collection, err := createCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := collection.Add(Order{Items: []LineItem{
+		{SKU: "abc", Quantity: 1},
+		{SKU: "def", Quantity: 2},
+		{SKU: "ghi", Quantity: 3},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = doc.Update(false,
+		update.Increment(update.FilteredPositional("items", "item", "quantity"), 5),
+		update.ArrayFilters(filter.Equal("item.sku", "def")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = collection.UpdateDocumentsWhere(
+		filter.AND(filter.Equal("_id", doc.ID), filter.Equal("items.sku", "ghi")),
+		false,
+		update.Set(update.Positional("items", "quantity"), 0),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = collection.Bulk(func(bulk *wrap.BulkCollection) error {
+		return bulk.Document(doc.ID).Update(false,
+			update.Set(update.FilteredPositional("items", "item", "shipped"), true),
+			update.ArrayFilters(filter.GreaterThan("item.quantity", 0)),
+		)
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := doc.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order := Order{}
+
+	err = data.DataTo(&order)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []LineItem{
+		{SKU: "abc", Quantity: 1, Shipped: true},
+		{SKU: "def", Quantity: 7, Shipped: true},
+		{SKU: "ghi", Quantity: 0, Shipped: false},
+	}
+
+	if !reflect.DeepEqual(order.Items, expected) {
+		t.Fatalf("unexpected items %+v", order.Items)
+	}
+
+	err = collection.Database.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDocumentArrayMaintenance(t *testing.T) {
+	collection, err := createCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := collection.Add(map[string]interface{}{
+		"scores": []int{2, 5, 8, 9},
+		"tags":   []string{"blue"},
+		"items":  []LineItem{{SKU: "abc", Quantity: 1}, {SKU: "def", Quantity: 2}},
+		"old":    1,
+		"older":  2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = doc.Update(false,
+		update.Pull("scores", filter.GreaterThanOrEqual("", 8)),
+		update.Pull("items", filter.Equal("sku", "abc")),
+		update.AddAllToSet("tags", "blue", "fish"),
+		update.Unset("old", "older"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = doc.Update(false, update.PushOne("scores", 1, update.Position(0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = doc.Update(false, update.Pull("scores", filter.AND(filter.GreaterThan("", 1), filter.LessThan("", 5))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = doc.Update(false, update.Pull("scores", filter.OR(filter.LessThan("", 1), filter.GreaterThan("", 5))))
+	if err == nil {
+		t.Fatal("expected an error for a Pull with OR of values")
+	}
+
+	data, err := doc.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := struct {
+		Scores []int       `bson:"scores"`
+		Tags   []string    `bson:"tags"`
+		Items  []LineItem  `bson:"items"`
+		Old    interface{} `bson:"old"`
+		Older  interface{} `bson:"older"`
+	}{}
+
+	err = data.DataTo(&result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(result.Scores, []int{1, 5}) ||
+		!reflect.DeepEqual(result.Tags, []string{"blue", "fish"}) ||
+		len(result.Items) != 1 || result.Items[0].SKU != "def" ||
+		result.Old != nil || result.Older != nil {
+		t.Fatalf("unexpected document %+v", result)
+	}
+
+	err = collection.Database.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,162 @@
+package wrap
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+var (
+	// ErrNotFound is returned if a document does not exist
+	ErrNotFound = errors.New("wrap: document not found")
+	// ErrDuplicateKey is returned if a write violates a unique index. The returned error is a *DuplicateKeyError
+	ErrDuplicateKey = errors.New("wrap: duplicate key")
+	// ErrWriteConflict is returned if a write conflicts with another write, for example in a concurrent transaction
+	ErrWriteConflict = errors.New("wrap: write conflict")
+	// ErrTimeout is returned if an operation did not complete before the timeout or context deadline
+	ErrTimeout = errors.New("wrap: operation timed out")
+	// ErrInvalidID is returned if a document ID is not valid
+	ErrInvalidID = errors.New("wrap: invalid document id")
+	// ErrClosed is returned if an operation is queued on a closed bulk writer
+	ErrClosed = errors.New("wrap: bulk writer is closed")
+	// ErrValidation is returned if a written document does not match the validator of the collection
+	ErrValidation = errors.New("wrap: document failed validation")
+)
+
+// Error is an error of a known kind. Use errors.Is with one of the Err
+// variables to check the kind of an error
+type Error struct {
+	// Kind is one of the Err variables
+	Kind error
+	// Err is the underlying error
+	Err error
+}
+
+func (e *Error) Error() string {
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports if the error is of the target kind
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+// DuplicateKeyError is returned if a write violates a unique index
+type DuplicateKeyError struct {
+	// Index is the name of the violated index
+	Index string
+	// Key is the duplicate key as reported by the server
+	Key string
+	// Err is the underlying error
+	Err error
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return ErrDuplicateKey.Error() + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *DuplicateKeyError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports if the target is ErrDuplicateKey
+func (e *DuplicateKeyError) Is(target error) bool {
+	return target == ErrDuplicateKey
+}
+
+const (
+	codeMaxTimeMSExpired = 50
+	codeWriteConflict    = 112
+	codeValidation       = 121
+)
+
+var duplicateKeyCodes = map[int]bool{
+	11000: true,
+	11001: true,
+	12582: true,
+}
+
+var duplicateKeyMessage = regexp.MustCompile(`index: (\S+) dup key: (\{.*\})`)
+
+// convertError classifies errors returned by the driver. Errors that can not
+// be classified are returned unchanged
+func convertError(err error) error {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *Error, *DuplicateKeyError:
+		return err
+	case mongo.CommandError:
+		return classify(err, int(e.Code), e.Message)
+	case mongo.WriteException:
+		if len(e.WriteErrors) > 0 {
+			return classify(err, e.WriteErrors[0].Code, e.WriteErrors[0].Message)
+		}
+		if e.WriteConcernError != nil {
+			return classify(err, e.WriteConcernError.Code, e.WriteConcernError.Message)
+		}
+	case mongo.BulkWriteException:
+		if len(e.WriteErrors) > 0 {
+			return classify(err, e.WriteErrors[0].Code, e.WriteErrors[0].Message)
+		}
+		if e.WriteConcernError != nil {
+			return classify(err, e.WriteConcernError.Code, e.WriteConcernError.Message)
+		}
+	case topology.ConnectionError:
+		if isTimeout(e.Wrapped) {
+			return &Error{Kind: ErrTimeout, Err: err}
+		}
+	}
+
+	if err == mongo.ErrNoDocuments {
+		return &Error{Kind: ErrNotFound, Err: err}
+	}
+
+	if isTimeout(err) || strings.Contains(err.Error(), topology.ErrServerSelectionTimeout.Error()) {
+		return &Error{Kind: ErrTimeout, Err: err}
+	}
+
+	return err
+}
+
+func classify(err error, code int, message string) error {
+	switch {
+	case duplicateKeyCodes[code]:
+		dupErr := &DuplicateKeyError{Err: err}
+
+		match := duplicateKeyMessage.FindStringSubmatch(message)
+		if match != nil {
+			dupErr.Index = match[1]
+			dupErr.Key = match[2]
+		}
+
+		return dupErr
+	case code == codeWriteConflict:
+		return &Error{Kind: ErrWriteConflict, Err: err}
+	case code == codeMaxTimeMSExpired:
+		return &Error{Kind: ErrTimeout, Err: err}
+	case code == codeValidation:
+		return &Error{Kind: ErrValidation, Err: err}
+	}
+
+	return err
+}
+
+func isTimeout(err error) bool {
+	if err == context.DeadlineExceeded {
+		return true
+	}
+
+	timeoutErr, ok := err.(interface{ Timeout() bool })
+	return ok && timeoutErr.Timeout()
+}
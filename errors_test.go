@@ -0,0 +1,63 @@
+package wrap_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lucacasonato/wrap"
+)
+
+func TestErrorNotFound(t *testing.T) {
+	collection, err := createCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = collection.Document(wrap.NewObjectID()).Get()
+	if !errors.Is(err, wrap.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestErrorInvalidID(t *testing.T) {
+	_, err := wrap.ObjectIDFromHex("not an id")
+	if !errors.Is(err, wrap.ErrInvalidID) {
+		t.Fatalf("expected ErrInvalidID, got %v", err)
+	}
+}
+
+func TestErrorDuplicateKey(t *testing.T) {
+	collection, err := createCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fish := map[string]interface{}{
+		"_id":  wrap.NewObjectID(),
+		"name": "the purple fish",
+	}
+
+	_, err = collection.Add(fish)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = collection.Add(fish)
+	if !errors.Is(err, wrap.ErrDuplicateKey) {
+		t.Fatalf("expected ErrDuplicateKey, got %v", err)
+	}
+
+	var dupErr *wrap.DuplicateKeyError
+	if !errors.As(err, &dupErr) {
+		t.Fatalf("expected DuplicateKeyError, got %v", err)
+	}
+
+	if dupErr.Index != "_id_" {
+		t.Fatalf("expected index _id_, got %s", dupErr.Index)
+	}
+
+	err = collection.Database.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
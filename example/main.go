@@ -40,21 +40,23 @@ func main() {
 		panic(err)
 	}
 
-	err = users.Bulk(func(users *wrap.BulkCollection) error {
-		users.Add(&User{
+	_, err = users.Bulk(func(users *wrap.BulkCollection) error {
+		err := users.Add(&User{
 			Name:            "Luca Casonato",
 			Email:           "luca.casonato@antipy.com",
 			FavoriteNumbers: []int{5, 10, 15},
 			LastEdited:      time.Now(),
 		})
-		users.Add(&User{
+		if err != nil {
+			return err
+		}
+
+		return users.Add(&User{
 			Name:            "Jaap Aarts",
 			Email:           "jaap.aarts@antipy.com",
 			FavoriteNumbers: []int{20, 4, 100},
 			LastEdited:      time.Now(),
 		})
-
-		return nil
 	}, false)
 	if err != nil {
 		panic(err)
@@ -65,12 +67,12 @@ func main() {
 	err = users.Transaction(func(users *wrap.Collection) error {
 		now := time.Now()
 
-		err = users.UpdateDocumentsWhere(filter.Equal("email", "luca.casonato@antipy.com"), true, update.Set("lastedited", now), update.Set("email", "luca@antipy.com"))
+		_, err = users.UpdateDocumentsWhere(filter.Equal("email", "luca.casonato@antipy.com"), true, update.Set("lastedited", now), update.Set("email", "luca@antipy.com"))
 		if err != nil {
 			return err
 		}
 
-		err = users.UpdateDocumentsWhere(filter.Equal("email", "jaap.aarts@antipy.com"), true, update.Set("lastedited", now))
+		_, err = users.UpdateDocumentsWhere(filter.Equal("email", "jaap.aarts@antipy.com"), true, update.Set("lastedited", now))
 		if err != nil {
 			return err
 		}
@@ -880,6 +880,11 @@ func Value(field string) interface{} {
 	return "$" + field
 }
 
+// Variable is the value of a variable, for example one defined with Let or the let variables of a join
+func Variable(name string) interface{} {
+	return "$$" + name
+}
+
 // Literal makes arbitrary data an expression without parsing
 func Literal(data interface{}) interface{} {
 	return data
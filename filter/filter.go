@@ -1,6 +1,11 @@
 package filter
 
 import (
+	"fmt"
+	"strings"
+
+	"github.com/lucacasonato/wrap/geo"
+	"github.com/lucacasonato/wrap/internal/bsonutil"
 	"github.com/lucacasonato/wrap/types"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -54,11 +59,30 @@ func LessThanOrEqual(field string, value interface{}) Filter {
 	})
 }
 
-// ArrayContains matches if the array in the field contains the specified value
+// ArrayContains matches if the array in the field contains the specified value. Uses $eq, so it also
+// matches if the field itself is equal to the value
 func ArrayContains(field string, value interface{}) Filter {
 	return Filter(&bson.M{
 		field: bson.M{
-			"$in": value,
+			"$eq": value,
+		},
+	})
+}
+
+// In matches if the field value is equal to any of the specified values, or if the array in the field contains any of them
+func In(field string, values ...interface{}) Filter {
+	return Filter(&bson.M{
+		field: bson.M{
+			"$in": list(values),
+		},
+	})
+}
+
+// NotIn matches if the field value is not equal to any of the specified values and the array in the field contains none of them
+func NotIn(field string, values ...interface{}) Filter {
+	return Filter(&bson.M{
+		field: bson.M{
+			"$nin": list(values),
 		},
 	})
 }
@@ -72,45 +96,164 @@ func NotEqual(field string, value interface{}) Filter {
 	})
 }
 
-// ArrayNotContains matches if the array in the field does not contain the specified value
+// ArrayNotContains matches if the array in the field does not contain the specified value. Uses $ne, so it
+// also matches if the field does not exist or is not equal to the value
 func ArrayNotContains(field string, value interface{}) Filter {
 	return Filter(&bson.M{
 		field: bson.M{
-			"$nin": value,
+			"$ne": value,
 		},
 	})
 }
 
-// AND matches if all of the filters match
+// AND matches if all of the filters match. Without filters all documents match
 func AND(filters ...Filter) Filter {
+	filters = nonNil(filters)
+
+	if len(filters) == 0 {
+		return Filter(&bson.M{})
+	}
+
+	if len(filters) == 1 {
+		return filters[0]
+	}
+
 	return Filter(&bson.M{
 		"$and": filters,
 	})
 }
 
-// OR matches if any of the filters match
+// OR matches if any of the filters match. Without filters no documents match
 func OR(filters ...Filter) Filter {
+	filters = nonNil(filters)
+
+	if len(filters) == 0 {
+		return matchNone()
+	}
+
+	if len(filters) == 1 {
+		return filters[0]
+	}
+
 	return Filter(&bson.M{
 		"$or": filters,
 	})
 }
 
-// NOT matches if the filter does not match and doesn't match if the filter matches
+// NOT matches if the filter does not match and doesn't match if the filter matches. A filter on a single
+// field is negated with $not on the field, other filters with $nor. Note that a negated field filter also
+// matches documents that do not have the field. TextSearch, Near and JavascriptExpression filters can not be
+// negated, the server rejects the $nor that NOT creates for them
 func NOT(filter Filter) Filter {
+	if filter == nil || len(*filter) == 0 {
+		return matchNone()
+	}
+
+	if len(*filter) == 1 {
+		for key, value := range *filter {
+			if key == "$nor" {
+				// NOT of NOR with a single filter is the filter itself
+				if filters, ok := value.([]Filter); ok && len(filters) == 1 {
+					return filters[0]
+				}
+
+				break
+			}
+
+			if strings.HasPrefix(key, "$") {
+				break
+			}
+
+			return Filter(&bson.M{
+				key: negate(value),
+			})
+		}
+	}
+
 	return Filter(&bson.M{
-		"$not": bson.A{
-			filter,
-		},
+		"$nor": []Filter{filter},
 	})
 }
 
-// NOR matches if all filters are false
+// NOR matches if all filters are false. Without filters all documents match
 func NOR(filters ...Filter) Filter {
+	filters = nonNil(filters)
+
+	if len(filters) == 0 {
+		return Filter(&bson.M{})
+	}
+
 	return Filter(&bson.M{
 		"$nor": filters,
 	})
 }
 
+// negate returns the negation of the value of a field in a filter
+func negate(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.M:
+		if not, ok := v["$not"]; ok && len(v) == 1 {
+			return not
+		}
+
+		// $not takes a regular expression directly, $regex is only allowed in $not by newer servers
+		if regex, ok := v["$regex"].(primitive.Regex); ok && len(v) == 1 {
+			return bson.M{"$not": regex}
+		}
+
+		if isOperators(v) {
+			return bson.M{"$not": v}
+		}
+	case primitive.Regex:
+		return bson.M{"$not": v}
+	}
+
+	return bson.M{"$ne": value}
+}
+
+// isOperators checks if all keys of the document are query operators
+func isOperators(doc bson.M) bool {
+	if len(doc) == 0 {
+		return false
+	}
+
+	for key := range doc {
+		if !strings.HasPrefix(key, "$") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchNone is a filter that matches no documents
+func matchNone() Filter {
+	return Filter(&bson.M{
+		"$nor": []Filter{Filter(&bson.M{})},
+	})
+}
+
+func nonNil(filters []Filter) []Filter {
+	result := []Filter{}
+
+	for _, filter := range filters {
+		if filter != nil {
+			result = append(result, filter)
+		}
+	}
+
+	return result
+}
+
+// list makes sure values are encoded as an array, also if there are no values
+func list(values []interface{}) []interface{} {
+	if values == nil {
+		return []interface{}{}
+	}
+
+	return values
+}
+
 // Exists matches if the field exists or not
 func Exists(field string, exists bool) Filter {
 	return Filter(&bson.M{
@@ -129,7 +272,107 @@ func IsType(field string, typ types.Type) Filter {
 	})
 }
 
-// TODO: implement schema
+// Near matches if the field is near the point and sorts the documents from nearest to farthest.
+// Distances are in meters. A maxDistance of 0 means no limit. Requires a 2dsphere index on the field
+func Near(field string, point geo.Point, minDistance float64, maxDistance float64) Filter {
+	near := bson.M{
+		"$geometry":    point,
+		"$minDistance": minDistance,
+	}
+
+	if maxDistance > 0 {
+		near["$maxDistance"] = maxDistance
+	}
+
+	return Filter(&bson.M{
+		field: bson.M{
+			"$near": near,
+		},
+	})
+}
+
+// GeoWithin matches if the field is completely within the polygon or multi polygon
+func GeoWithin(field string, area geo.Area) Filter {
+	return Filter(&bson.M{
+		field: bson.M{
+			"$geoWithin": bson.M{
+				"$geometry": area,
+			},
+		},
+	})
+}
+
+// GeoWithinBox matches if the legacy coordinate pair in the field is within the box
+func GeoWithinBox(field string, bottomLeft geo.Position, topRight geo.Position) Filter {
+	return Filter(&bson.M{
+		field: bson.M{
+			"$geoWithin": bson.M{
+				"$box": bson.A{
+					bottomLeft,
+					topRight,
+				},
+			},
+		},
+	})
+}
+
+// GeoWithinCircle matches if the legacy coordinate pair in the field is within the flat circle
+func GeoWithinCircle(field string, center geo.Position, radius float64) Filter {
+	return Filter(&bson.M{
+		field: bson.M{
+			"$geoWithin": bson.M{
+				"$center": bson.A{
+					center,
+					radius,
+				},
+			},
+		},
+	})
+}
+
+// GeoWithinPolygon matches if the legacy coordinate pair in the field is within the flat polygon
+func GeoWithinPolygon(field string, positions ...geo.Position) Filter {
+	return Filter(&bson.M{
+		field: bson.M{
+			"$geoWithin": bson.M{
+				"$polygon": positions,
+			},
+		},
+	})
+}
+
+// GeoWithinCenterSphere matches if the field is within the circle on the surface of the earth.
+// The radius is in radians, use geo.KilometersToRadians to convert distances
+func GeoWithinCenterSphere(field string, center geo.Position, radius float64) Filter {
+	return Filter(&bson.M{
+		field: bson.M{
+			"$geoWithin": bson.M{
+				"$centerSphere": bson.A{
+					center,
+					radius,
+				},
+			},
+		},
+	})
+}
+
+// GeoIntersects matches if the field intersects with the geometry
+func GeoIntersects(field string, geometry geo.Geometry) Filter {
+	return Filter(&bson.M{
+		field: bson.M{
+			"$geoIntersects": bson.M{
+				"$geometry": geometry,
+			},
+		},
+	})
+}
+
+// Schema matches if the document is valid for the schema. Also used as the validator of a collection
+func Schema(schema *JSONSchema) Filter {
+	return Filter(&bson.M{
+		"$jsonSchema": schema,
+	})
+}
 
 // Regex matches if the field value matches the regular expression
 func Regex(field string, regex string) Filter {
@@ -161,6 +404,14 @@ func Modulo(field string, divisor int, remainder int) Filter {
 	})
 }
 
+// Expression matches if the aggregation expression evaluates to true. Use this to compare fields of the same document
+// or to use variables, for example in the query of a JoinQuery
+func Expression(expression interface{}) Filter {
+	return Filter(&bson.M{
+		"$expr": expression,
+	})
+}
+
 // JavascriptExpression matches if the field contains the specified text
 func JavascriptExpression(expression string) Filter {
 	return Filter(&bson.M{
@@ -172,20 +423,59 @@ func JavascriptExpression(expression string) Filter {
 func ArrayAll(field string, items []interface{}) Filter {
 	return Filter(&bson.M{
 		field: bson.M{
-			"$all": items,
+			"$all": list(items),
 		},
 	})
 }
 
-// ArraySingleMatch matches if any of the items in the array match all filters
+// ArraySingleMatch matches if any of the items in the array match all filters. For arrays of
+// documents use filters on the fields of the items, for example Equal("name", "nemo"). For arrays
+// of values use filters with an empty field, for example GreaterThan("", 5), which can be combined
+// with AND. Filters with an empty field can not be mixed with filters on fields or combined with OR
+// or NOR, running a query with such a filter returns an error
 func ArraySingleMatch(field string, filters ...Filter) Filter {
+	filters = nonNil(filters)
+
+	var elemMatch interface{}
+
+	operators, values, err := bsonutil.ValueCondition(*AND(filters...))
+
+	switch {
+	case err != nil:
+		elemMatch = bsonutil.Invalid{Err: fmt.Errorf("filter: ArraySingleMatch on %s: %v", field, err)}
+	case values:
+		elemMatch = operators
+	default:
+		elemMatch = fieldMatch(filters)
+	}
+
 	return Filter(&bson.M{
 		field: bson.M{
-			"$elemMatch": filters,
+			"$elemMatch": elemMatch,
 		},
 	})
 }
 
+// fieldMatch combines filters on the fields of array items into the document of $elemMatch
+func fieldMatch(filters []Filter) bson.D {
+	match := bson.D{}
+	seen := map[string]bool{}
+
+	for _, filter := range filters {
+		for _, key := range bsonutil.SortedKeys(*filter) {
+			// filters on the same field are combined with $and
+			if seen[key] {
+				return bson.D{{Key: "$and", Value: filters}}
+			}
+
+			seen[key] = true
+			match = append(match, bson.E{Key: key, Value: (*filter)[key]})
+		}
+	}
+
+	return match
+}
+
 // ArraySize matches if the array has the specified size
 func ArraySize(field string, size int) Filter {
 	return Filter(&bson.M{
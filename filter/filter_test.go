@@ -0,0 +1,241 @@
+package filter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lucacasonato/wrap/filter"
+	"github.com/lucacasonato/wrap/geo"
+	"github.com/lucacasonato/wrap/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+func marshal(t *testing.T, f filter.Filter) bson.Raw {
+	raw, err := bson.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return raw
+}
+
+// fieldFilters are all filters on a single field that can be negated
+var fieldFilters = map[string]filter.Filter{
+	"Equal":                 filter.Equal("a", 1),
+	"GreaterThan":           filter.GreaterThan("a", 1),
+	"GreaterThanOrEqual":    filter.GreaterThanOrEqual("a", 1),
+	"LessThan":              filter.LessThan("a", 1),
+	"LessThanOrEqual":       filter.LessThanOrEqual("a", 1),
+	"ArrayContains":         filter.ArrayContains("a", 1),
+	"ArrayNotContains":      filter.ArrayNotContains("a", 1),
+	"In":                    filter.In("a", 1, 2, 3),
+	"InNone":                filter.In("a"),
+	"NotIn":                 filter.NotIn("a", 1, 2, 3),
+	"NotEqual":              filter.NotEqual("a", 1),
+	"Exists":                filter.Exists("a", true),
+	"IsType":                filter.IsType("a", types.String),
+	"GeoWithin":             filter.GeoWithin("a", geo.NewPolygon([]geo.Position{{0, 0}, {1, 0}, {1, 1}, {0, 0}})),
+	"GeoWithinBox":          filter.GeoWithinBox("a", geo.Position{0, 0}, geo.Position{1, 1}),
+	"GeoWithinCircle":       filter.GeoWithinCircle("a", geo.Position{0, 0}, 1),
+	"GeoWithinPolygon":      filter.GeoWithinPolygon("a", geo.Position{0, 0}, geo.Position{1, 0}, geo.Position{1, 1}),
+	"GeoWithinCenterSphere": filter.GeoWithinCenterSphere("a", geo.Position{0, 0}, 1),
+	"GeoIntersects":         filter.GeoIntersects("a", geo.NewPoint(0, 0)),
+	"Regex":                 filter.Regex("a", "^fish"),
+	"Modulo":                filter.Modulo("a", 2, 0),
+	"ArrayAll":              filter.ArrayAll("a", []interface{}{1, 2}),
+	"ArrayAllNone":          filter.ArrayAll("a", nil),
+	"ArraySize":             filter.ArraySize("a", 2),
+	"ArraySingleMatch":      filter.ArraySingleMatch("a", filter.Equal("b", 1), filter.GreaterThan("c", 2)),
+	"ArraySingleMatchAND":   filter.ArraySingleMatch("a", filter.GreaterThan("b", 1), filter.LessThan("b", 5)),
+	"ArraySingleMatchValue": filter.ArraySingleMatch("a", filter.GreaterThan("", 1), filter.NOT(filter.Equal("", 3))),
+	"BitsAll0":              filter.BitsAll0("a", 3),
+	"BitsAll1":              filter.BitsAll1("a", 3),
+	"BitsAny0":              filter.BitsAny0("a", 3),
+	"BitsAny1":              filter.BitsAny1("a", 3),
+}
+
+// documentFilters are filters that are not on a single field
+var documentFilters = map[string]filter.Filter{
+	"AND":                  filter.AND(filter.Equal("a", 1), filter.Equal("b", 2)),
+	"OR":                   filter.OR(filter.Equal("a", 1), filter.Equal("b", 2)),
+	"NORTwo":               filter.NOR(filter.Equal("a", 1), filter.Equal("b", 2)),
+	"Expression":           filter.Expression(bson.M{"$eq": bson.A{"$a", "$b"}}),
+	"JavascriptExpression": filter.JavascriptExpression("this.a == this.b"),
+	"Schema":               filter.Schema(filter.NewSchema(types.Object).Required("a")),
+	"TwoFields":            filter.Filter(&bson.M{"a": 1, "b": 2}),
+}
+
+func TestArrayContains(t *testing.T) {
+	for f, expected := range map[filter.Filter]string{
+		filter.ArrayContains("a", 1):    `{"a": {"$eq": {"$numberInt":"1"}}}`,
+		filter.ArrayNotContains("a", 1): `{"a": {"$ne": {"$numberInt":"1"}}}`,
+	} {
+		raw := marshal(t, f)
+
+		if raw.String() != expected {
+			t.Errorf("expected %s, got %s", expected, raw)
+		}
+	}
+}
+
+func TestNOTField(t *testing.T) {
+	for name, f := range fieldFilters {
+		raw := marshal(t, filter.NOT(f))
+
+		original, err := marshal(t, f).LookupErr("a")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		not, err := raw.LookupErr("a", "$not")
+		if err != nil {
+			t.Errorf("%s: expected $not under the field, got %s", name, raw)
+			continue
+		}
+
+		if name == "Regex" {
+			if not.Type != bsontype.Regex {
+				t.Errorf("%s: expected $not with a regular expression, got %s", name, raw)
+			}
+
+			continue
+		}
+
+		if !not.Equal(original) {
+			t.Errorf("%s: expected $not of %s, got %s", name, original, raw)
+		}
+
+		double, err := marshal(t, filter.NOT(filter.NOT(f))).LookupErr("a")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !double.Equal(original) {
+			t.Errorf("%s: expected NOT(NOT(f)) to be %s, got %s", name, original, double)
+		}
+	}
+}
+
+func TestNOTCompound(t *testing.T) {
+	f := filter.AND(filter.Equal("a", 1), filter.Equal("b", 2))
+
+	raw := marshal(t, filter.NOT(f))
+
+	nor, err := raw.LookupErr("$nor", "0")
+	if err != nil {
+		t.Fatalf("expected $nor, got %s", raw)
+	}
+
+	if !nor.Equal(bson.RawValue{Type: bsontype.EmbeddedDocument, Value: marshal(t, f)}) {
+		t.Fatalf("expected $nor of the filter, got %s", raw)
+	}
+
+	double := marshal(t, filter.NOT(filter.NOT(f)))
+	if double.String() != marshal(t, f).String() {
+		t.Fatalf("expected NOT(NOT(f)) to be f, got %s", double)
+	}
+}
+
+func TestNOTDocument(t *testing.T) {
+	for name, f := range documentFilters {
+		not := filter.NOT(f)
+
+		nor, ok := (*not)["$nor"].([]filter.Filter)
+		if len(*not) != 1 || !ok || len(nor) != 1 || nor[0] != f {
+			t.Errorf("%s: expected $nor of the filter, got %s", name, marshal(t, not))
+		}
+	}
+
+	for name, f := range map[string]filter.Filter{
+		"NOTNil":  filter.NOT(nil),
+		"NOTNone": filter.NOT(filter.AND()),
+		"ORNone":  filter.OR(),
+	} {
+		raw := marshal(t, f)
+
+		if raw.String() != `{"$nor": [{}]}` {
+			t.Errorf("%s: expected a filter that matches nothing, got %s", name, raw)
+		}
+	}
+}
+
+func TestIn(t *testing.T) {
+	raw := marshal(t, filter.In("a", 1, 2))
+
+	values, err := raw.LookupErr("a", "$in")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if values.String() != `[{"$numberInt":"1"},{"$numberInt":"2"}]` {
+		t.Fatalf("unexpected values %s", values)
+	}
+
+	raw = marshal(t, filter.NotIn("a", "b"))
+
+	values, err = raw.LookupErr("a", "$nin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if values.String() != `["b"]` {
+		t.Fatalf("unexpected values %s", values)
+	}
+}
+
+func TestArraySingleMatch(t *testing.T) {
+	for _, test := range []struct {
+		filter   filter.Filter
+		expected string
+	}{
+		{
+			filter:   filter.ArraySingleMatch("a", filter.Equal("b", 1)),
+			expected: `{"b": {"$eq": {"$numberInt":"1"}}}`,
+		},
+		{
+			filter:   filter.ArraySingleMatch("a", filter.GreaterThan("b", 1), filter.LessThan("b", 5)),
+			expected: `{"$and": [{"b": {"$gt": {"$numberInt":"1"}}},{"b": {"$lt": {"$numberInt":"5"}}}]}`,
+		},
+		{
+			filter:   filter.ArraySingleMatch("a", filter.GreaterThanOrEqual("", 80)),
+			expected: `{"$gte": {"$numberInt":"80"}}`,
+		},
+		{
+			filter:   filter.ArraySingleMatch("a", filter.AND(filter.GreaterThan("", 1), filter.LessThan("", 5))),
+			expected: `{"$gt": {"$numberInt":"1"},"$lt": {"$numberInt":"5"}}`,
+		},
+		{
+			filter:   filter.ArraySingleMatch("a", filter.AND(filter.GreaterThan("", 1)), filter.NOT(filter.Equal("", 3))),
+			expected: `{"$gt": {"$numberInt":"1"},"$not": {"$eq": {"$numberInt":"3"}}}`,
+		},
+		{
+			filter:   filter.ArraySingleMatch("a", filter.NOT(filter.Equal("", 3))),
+			expected: `{"$not": {"$eq": {"$numberInt":"3"}}}`,
+		},
+	} {
+		match, err := marshal(t, test.filter).LookupErr("a", "$elemMatch")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if match.String() != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, match)
+		}
+	}
+}
+
+func TestArraySingleMatchMixed(t *testing.T) {
+	for _, f := range []filter.Filter{
+		filter.ArraySingleMatch("a", filter.Equal("b", 1), filter.GreaterThan("", 2)),
+		filter.ArraySingleMatch("a", filter.GreaterThan("", 2), filter.AND(filter.Equal("b", 1), filter.Equal("c", 2))),
+		filter.ArraySingleMatch("a", filter.Filter(&bson.M{"": 1, "b": 2})),
+		filter.ArraySingleMatch("a", filter.OR(filter.LessThan("", 1), filter.GreaterThan("", 5))),
+		filter.ArraySingleMatch("a", filter.GreaterThan("", 1), filter.GreaterThan("", 2)),
+	} {
+		_, err := bson.Marshal(filter.AND(f, filter.Equal("z", 1)))
+		if err == nil || !strings.Contains(err.Error(), "ArraySingleMatch on a") {
+			t.Errorf("expected an error for mixed filters, got %v", err)
+		}
+	}
+}
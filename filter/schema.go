@@ -0,0 +1,127 @@
+package filter
+
+import (
+	"github.com/lucacasonato/wrap/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// JSONSchema describes the allowed values of a document or field. Build it with
+// NewSchema and the chainable methods, and use it with Schema. The methods return
+// a new schema, so a schema can be reused as the base of several schemas
+type JSONSchema struct {
+	schema bson.M
+}
+
+// NewSchema creates a schema for values of any of the types. Without types all types are allowed
+func NewSchema(typs ...types.Type) *JSONSchema {
+	s := &JSONSchema{schema: bson.M{}}
+
+	if len(typs) == 1 {
+		s.schema["bsonType"] = typs[0]
+	} else if len(typs) > 1 {
+		s.schema["bsonType"] = typs
+	}
+
+	return s
+}
+
+// Description of the value
+func (s *JSONSchema) Description(description string) *JSONSchema {
+	return s.set("description", description)
+}
+
+// Required fields of an object
+func (s *JSONSchema) Required(fields ...string) *JSONSchema {
+	return s.set("required", append([]string(nil), fields...))
+}
+
+// Property sets the schema of a field of an object
+func (s *JSONSchema) Property(field string, schema *JSONSchema) *JSONSchema {
+	properties := bson.M{}
+	if existing, ok := s.schema["properties"].(bson.M); ok {
+		for key, value := range existing {
+			properties[key] = value
+		}
+	}
+
+	properties[field] = schema
+
+	return s.set("properties", properties)
+}
+
+// AdditionalProperties allows or forbids fields of an object that are not listed as properties
+func (s *JSONSchema) AdditionalProperties(allowed bool) *JSONSchema {
+	return s.set("additionalProperties", allowed)
+}
+
+// AdditionalPropertiesSchema sets the schema of fields of an object that are not listed as properties
+func (s *JSONSchema) AdditionalPropertiesSchema(schema *JSONSchema) *JSONSchema {
+	return s.set("additionalProperties", schema)
+}
+
+// Enum only allows the listed values
+func (s *JSONSchema) Enum(values ...interface{}) *JSONSchema {
+	return s.set("enum", append([]interface{}(nil), values...))
+}
+
+// Minimum value of a number
+func (s *JSONSchema) Minimum(min float64) *JSONSchema {
+	return s.set("minimum", min)
+}
+
+// Maximum value of a number
+func (s *JSONSchema) Maximum(max float64) *JSONSchema {
+	return s.set("maximum", max)
+}
+
+// MinLength is the minimum length of a string
+func (s *JSONSchema) MinLength(min int) *JSONSchema {
+	return s.set("minLength", min)
+}
+
+// MaxLength is the maximum length of a string
+func (s *JSONSchema) MaxLength(max int) *JSONSchema {
+	return s.set("maxLength", max)
+}
+
+// Pattern is a regular expression a string must match
+func (s *JSONSchema) Pattern(pattern string) *JSONSchema {
+	return s.set("pattern", pattern)
+}
+
+// Items sets the schema of all items of an array
+func (s *JSONSchema) Items(schema *JSONSchema) *JSONSchema {
+	return s.set("items", schema)
+}
+
+// MinItems is the minimum length of an array
+func (s *JSONSchema) MinItems(min int) *JSONSchema {
+	return s.set("minItems", min)
+}
+
+// MaxItems is the maximum length of an array
+func (s *JSONSchema) MaxItems(max int) *JSONSchema {
+	return s.set("maxItems", max)
+}
+
+// UniqueItems forbids duplicate items in an array
+func (s *JSONSchema) UniqueItems() *JSONSchema {
+	return s.set("uniqueItems", true)
+}
+
+// set returns a copy of the schema with the key set to the value
+func (s *JSONSchema) set(key string, value interface{}) *JSONSchema {
+	schema := bson.M{}
+	for k, v := range s.schema {
+		schema[k] = v
+	}
+
+	schema[key] = value
+
+	return &JSONSchema{schema: schema}
+}
+
+// MarshalBSON encodes the schema as a document
+func (s *JSONSchema) MarshalBSON() ([]byte, error) {
+	return bson.Marshal(s.schema)
+}
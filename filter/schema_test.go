@@ -0,0 +1,105 @@
+package filter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lucacasonato/wrap/filter"
+	"github.com/lucacasonato/wrap/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSchema(t *testing.T) {
+	f := filter.Schema(filter.NewSchema(types.Object).
+		Required("name", "tags").
+		Property("name", filter.NewSchema(types.String).MinLength(1).Pattern("^[a-z ]+$")).
+		Property("weight", filter.NewSchema(types.Double, types.Int).Minimum(0).Maximum(100)).
+		Property("color", filter.NewSchema().Enum("red", "blue")).
+		Property("tags", filter.NewSchema(types.Array).Items(filter.NewSchema(types.String)).UniqueItems()).
+		AdditionalProperties(false))
+
+	raw, err := bson.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := bson.Raw(raw)
+
+	for path, expected := range map[string]string{
+		"$jsonSchema.bsonType":                       `"object"`,
+		"$jsonSchema.required":                       `["name","tags"]`,
+		"$jsonSchema.additionalProperties":           `false`,
+		"$jsonSchema.properties.name.minLength":      `{"$numberInt":"1"}`,
+		"$jsonSchema.properties.name.pattern":        `"^[a-z ]+$"`,
+		"$jsonSchema.properties.weight.bsonType":     `["double","int"]`,
+		"$jsonSchema.properties.weight.maximum":      `{"$numberDouble":"100.0"}`,
+		"$jsonSchema.properties.color.enum":          `["red","blue"]`,
+		"$jsonSchema.properties.tags.items.bsonType": `"string"`,
+		"$jsonSchema.properties.tags.uniqueItems":    `true`,
+	} {
+		value, err := doc.LookupErr(strings.Split(path, ".")...)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+
+		if value.String() != expected {
+			t.Fatalf("%s: expected %s, got %s", path, expected, value.String())
+		}
+	}
+
+	if _, err := doc.LookupErr("$jsonSchema", "properties", "color", "bsonType"); err == nil {
+		t.Fatal("expected no bsonType for a schema without types")
+	}
+}
+
+func TestSchemaReuse(t *testing.T) {
+	base := filter.NewSchema(types.Object).
+		Required("name").
+		Property("name", filter.NewSchema(types.String))
+
+	fish := base.Property("fins", filter.NewSchema(types.Int)).Required("name", "fins")
+	bird := base.Property("wings", filter.NewSchema(types.Int)).AdditionalProperties(false)
+
+	for name, expected := range map[string]struct {
+		schema   *filter.JSONSchema
+		absent   []string
+		present  []string
+		required string
+	}{
+		"base": {base, []string{"fins", "wings"}, []string{"name"}, `["name"]`},
+		"fish": {fish, []string{"wings"}, []string{"name", "fins"}, `["name","fins"]`},
+		"bird": {bird, []string{"fins"}, []string{"name", "wings"}, `["name"]`},
+	} {
+		raw, err := bson.Marshal(expected.schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		doc := bson.Raw(raw)
+
+		for _, field := range expected.present {
+			if _, err := doc.LookupErr("properties", field); err != nil {
+				t.Fatalf("%s: expected property %s: %v", name, field, err)
+			}
+		}
+
+		for _, field := range expected.absent {
+			if _, err := doc.LookupErr("properties", field); err == nil {
+				t.Fatalf("%s: unexpected property %s", name, field)
+			}
+		}
+
+		if required := doc.Lookup("required").String(); required != expected.required {
+			t.Fatalf("%s: expected required %s, got %s", name, expected.required, required)
+		}
+	}
+
+	raw, err := bson.Marshal(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := bson.Raw(raw).LookupErr("additionalProperties"); err == nil {
+		t.Fatal("base schema was changed by AdditionalProperties")
+	}
+}
@@ -0,0 +1,94 @@
+package geo
+
+// Position is a longitude and latitude pair. For legacy coordinate pairs it is an x and y pair
+type Position [2]float64
+
+// Geometry is a GeoJSON object
+type Geometry interface {
+	geometry()
+}
+
+// Area is a GeoJSON object that encloses an area, a Polygon or MultiPolygon
+type Area interface {
+	Geometry
+	area()
+}
+
+// Point is a GeoJSON point
+type Point struct {
+	Type        string   `bson:"type"`
+	Coordinates Position `bson:"coordinates"`
+}
+
+// LineString is a GeoJSON line of two or more points
+type LineString struct {
+	Type        string     `bson:"type"`
+	Coordinates []Position `bson:"coordinates"`
+}
+
+// Polygon is a GeoJSON polygon. The first ring is the exterior of the polygon and all further
+// rings are holes in it. Every ring must start and end with the same position
+type Polygon struct {
+	Type        string       `bson:"type"`
+	Coordinates [][]Position `bson:"coordinates"`
+}
+
+// MultiPolygon is a GeoJSON collection of polygons
+type MultiPolygon struct {
+	Type        string         `bson:"type"`
+	Coordinates [][][]Position `bson:"coordinates"`
+}
+
+func (Point) geometry()        {}
+func (LineString) geometry()   {}
+func (Polygon) geometry()      {}
+func (MultiPolygon) geometry() {}
+
+func (Polygon) area()      {}
+func (MultiPolygon) area() {}
+
+// NewPoint creates a point at the longitude and latitude
+func NewPoint(longitude float64, latitude float64) Point {
+	return Point{
+		Type:        "Point",
+		Coordinates: Position{longitude, latitude},
+	}
+}
+
+// NewLineString creates a line through the positions
+func NewLineString(positions ...Position) LineString {
+	return LineString{
+		Type:        "LineString",
+		Coordinates: positions,
+	}
+}
+
+// NewPolygon creates a polygon from the exterior ring and optional holes
+func NewPolygon(exterior []Position, holes ...[]Position) Polygon {
+	return Polygon{
+		Type:        "Polygon",
+		Coordinates: append([][]Position{exterior}, holes...),
+	}
+}
+
+// NewMultiPolygon creates a collection of polygons
+func NewMultiPolygon(polygons ...Polygon) MultiPolygon {
+	coordinates := [][][]Position{}
+
+	for _, polygon := range polygons {
+		coordinates = append(coordinates, polygon.Coordinates)
+	}
+
+	return MultiPolygon{
+		Type:        "MultiPolygon",
+		Coordinates: coordinates,
+	}
+}
+
+// EarthRadiusKilometers is the radius of the earth used to convert distances to radians
+const EarthRadiusKilometers = 6378.1
+
+// KilometersToRadians converts a distance on the surface of the earth to radians, for example for a center sphere
+func KilometersToRadians(km float64) float64 {
+	return km / EarthRadiusKilometers
+}
@@ -0,0 +1,244 @@
+package wrap
+
+import (
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const uuidSubtype = 4
+
+// ID is the identifier of a document. It can be an ObjectID, string, integer,
+// UUID or an embedded document
+type ID struct {
+	value interface{}
+}
+
+// NewObjectID generates a new ObjectID
+func NewObjectID() ID {
+	return ID{primitive.NewObjectID()}
+}
+
+// ObjectIDFromHex parses an ObjectID from its hex representation
+func ObjectIDFromHex(s string) (ID, error) {
+	objID, err := primitive.ObjectIDFromHex(s)
+	if err != nil {
+		return ID{}, &Error{Kind: ErrInvalidID, Err: err}
+	}
+
+	return ID{objID}, nil
+}
+
+// StringID is an ID that is a string
+func StringID(s string) ID {
+	return ID{s}
+}
+
+// IntID is an ID that is an integer
+func IntID(i int64) ID {
+	return ID{i}
+}
+
+// UUID is an ID that is a UUID
+func UUID(uuid [16]byte) ID {
+	return ID{primitive.Binary{Subtype: uuidSubtype, Data: uuid[:]}}
+}
+
+// UUIDFromString parses a UUID from its string representation (xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx)
+func UUIDFromString(s string) (ID, error) {
+	data, err := hex.DecodeString(strings.Replace(s, "-", "", -1))
+	if err != nil {
+		return ID{}, &Error{Kind: ErrInvalidID, Err: err}
+	}
+
+	if len(data) != 16 {
+		return ID{}, &Error{Kind: ErrInvalidID, Err: fmt.Errorf("uuid %q is not 16 bytes long", s)}
+	}
+
+	var uuid [16]byte
+	copy(uuid[:], data)
+
+	return UUID(uuid), nil
+}
+
+// EmbeddedID is an ID that is an embedded document, for example a struct or a bson.D with multiple
+// fields. The server compares embedded IDs field by field in order, so maps with more than one key
+// are rejected because their fields are encoded in random order
+func EmbeddedID(value interface{}) (ID, error) {
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Map && v.Len() > 1 {
+		return ID{}, &Error{Kind: ErrInvalidID, Err: fmt.Errorf("embedded id %v is a map with more than one key, use a struct or bson.D", value)}
+	}
+
+	raw, err := bson.Marshal(value)
+	if err != nil {
+		return ID{}, &Error{Kind: ErrInvalidID, Err: err}
+	}
+
+	var doc bson.D
+
+	err = bson.Unmarshal(raw, &doc)
+	if err != nil {
+		return ID{}, &Error{Kind: ErrInvalidID, Err: err}
+	}
+
+	return ID{doc}, nil
+}
+
+// idFromValue converts a value that was decoded by the driver to an ID
+func idFromValue(value interface{}) (ID, error) {
+	t, data, err := marshalValue(value)
+	if err != nil {
+		return ID{}, &Error{Kind: ErrInvalidID, Err: err}
+	}
+
+	var id ID
+
+	err = id.UnmarshalBSONValue(t, data)
+	if err != nil {
+		return ID{}, err
+	}
+
+	return id, nil
+}
+
+// IsZero returns true if the ID is not set
+func (id ID) IsZero() bool {
+	return id.value == nil
+}
+
+// Equal returns true if both IDs are the same
+func (id ID) Equal(other ID) bool {
+	t1, data1, err1 := marshalValue(id.value)
+	t2, data2, err2 := marshalValue(other.value)
+
+	return err1 == nil && err2 == nil && t1 == t2 && string(data1) == string(data2)
+}
+
+// String returns a readable representation of the ID. ObjectIDs are returned as hex and UUIDs in their string representation
+func (id ID) String() string {
+	switch value := id.value.(type) {
+	case nil:
+		return ""
+	case primitive.ObjectID:
+		return value.Hex()
+	case string:
+		return value
+	case primitive.Binary:
+		if value.Subtype == uuidSubtype && len(value.Data) == 16 {
+			d := value.Data
+			return fmt.Sprintf("%x-%x-%x-%x-%x", d[0:4], d[4:6], d[6:8], d[8:10], d[10:16])
+		}
+	case bson.D:
+		extJSON, err := bson.MarshalExtJSON(value, false, false)
+		if err == nil {
+			return string(extJSON)
+		}
+	}
+
+	return fmt.Sprint(id.value)
+}
+
+// Value returns the underlying value of the ID. This is a string for string IDs, an int64 for integer IDs,
+// a [16]byte for UUIDs, and a map[string]interface{} for embedded IDs. ObjectIDs are returned as hex.
+// Use DataTo to decode an embedded ID in field order
+func (id ID) Value() interface{} {
+	switch value := id.value.(type) {
+	case primitive.ObjectID:
+		return value.Hex()
+	case primitive.Binary:
+		if value.Subtype == uuidSubtype && len(value.Data) == 16 {
+			var uuid [16]byte
+			copy(uuid[:], value.Data)
+			return uuid
+		}
+	case bson.D:
+		return map[string]interface{}(value.Map())
+	}
+
+	return id.value
+}
+
+// DataTo decodes an embedded ID into an interface
+func (id ID) DataTo(data interface{}) error {
+	raw, err := bson.Marshal(id.value)
+	if err != nil {
+		return err
+	}
+
+	return bson.Unmarshal(raw, data)
+}
+
+// MarshalBSONValue encodes the ID
+func (id ID) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	if id.value == nil {
+		return bsontype.Null, nil, nil
+	}
+
+	return marshalValue(id.value)
+}
+
+// UnmarshalBSONValue decodes an ID
+func (id *ID) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	raw := bson.RawValue{Type: t, Value: data}
+
+	switch t {
+	case bsontype.ObjectID:
+		id.value = raw.ObjectID()
+	case bsontype.String:
+		id.value = raw.StringValue()
+	case bsontype.Int32:
+		id.value = int64(raw.Int32())
+	case bsontype.Int64:
+		id.value = raw.Int64()
+	case bsontype.Binary:
+		subtype, data := raw.Binary()
+		id.value = primitive.Binary{Subtype: subtype, Data: append([]byte{}, data...)}
+	case bsontype.EmbeddedDocument:
+		var doc bson.D
+
+		err := raw.Unmarshal(&doc)
+		if err != nil {
+			return &Error{Kind: ErrInvalidID, Err: err}
+		}
+
+		id.value = doc
+	case bsontype.Null, bsontype.Undefined:
+		id.value = nil
+	default:
+		var value interface{}
+
+		err := raw.Unmarshal(&value)
+		if err != nil {
+			return &Error{Kind: ErrInvalidID, Err: err}
+		}
+
+		id.value = value
+	}
+
+	return nil
+}
+
+func marshalValue(value interface{}) (bsontype.Type, []byte, error) {
+	raw, err := bson.Marshal(bson.D{{Key: "v", Value: value}})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	v := bson.Raw(raw).Lookup("v")
+
+	return v.Type, v.Value, nil
+}
+
+// idFilter returns a filter that matches the document with the ID
+func idFilter(id ID) (bson.M, error) {
+	if id.IsZero() {
+		return nil, &Error{Kind: ErrInvalidID, Err: fmt.Errorf("document id is not set")}
+	}
+
+	return bson.M{"_id": id}, nil
+}
@@ -0,0 +1,152 @@
+package wrap_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lucacasonato/wrap"
+	"github.com/lucacasonato/wrap/filter"
+)
+
+// Key is a compound document id
+type Key struct {
+	Tenant string `bson:"tenant"`
+	Number int    `bson:"number"`
+}
+
+func TestIDString(t *testing.T) {
+	objID, err := wrap.ObjectIDFromHex("0123456789abcdef01234567")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if objID.String() != "0123456789abcdef01234567" {
+		t.Fatalf("unexpected object id %s", objID)
+	}
+
+	uuid, err := wrap.UUIDFromString("123e4567-e89b-12d3-a456-426655440000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uuid.String() != "123e4567-e89b-12d3-a456-426655440000" {
+		t.Fatalf("unexpected uuid %s", uuid)
+	}
+
+	_, err = wrap.UUIDFromString("123e4567")
+	if err == nil {
+		t.Fatal("uuid did not error on an invalid uuid")
+	}
+
+	if wrap.IntID(5).Value() != int64(5) {
+		t.Fatalf("unexpected int id %v", wrap.IntID(5).Value())
+	}
+
+	if !wrap.StringID("fish").Equal(wrap.StringID("fish")) || wrap.StringID("fish").Equal(wrap.StringID("shark")) {
+		t.Fatal("string ids are not compared correctly")
+	}
+
+	embedded, err := wrap.EmbeddedID(Key{Tenant: "sea", Number: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, ok := embedded.Value().(map[string]interface{})
+	if !ok || value["tenant"] != "sea" {
+		t.Fatalf("unexpected embedded id value %#v", embedded.Value())
+	}
+
+	_, err = wrap.EmbeddedID(map[string]interface{}{"tenant": "sea", "number": 1})
+	if !errors.Is(err, wrap.ErrInvalidID) {
+		t.Fatalf("expected ErrInvalidID for a map with more than one key, got %v", err)
+	}
+
+	_, err = wrap.EmbeddedID(map[string]interface{}{"tenant": "sea"})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIDTypes(t *testing.T) {
+	collection, err := createCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uuid, err := wrap.UUIDFromString("123e4567-e89b-12d3-a456-426655440000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	embedded, err := wrap.EmbeddedID(Key{Tenant: "sea", Number: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []wrap.ID{
+		wrap.NewObjectID(),
+		wrap.StringID("the red fish"),
+		wrap.IntID(42),
+		uuid,
+		embedded,
+	}
+
+	for _, id := range ids {
+		_, err := collection.Document(id).Set(map[string]interface{}{
+			"name": id.String(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := collection.Document(id).Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fish := map[string]interface{}{}
+
+		err = data.DataTo(&fish)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if fish["name"] != id.String() {
+			t.Fatalf("expected %s, got %v", id, fish["name"])
+		}
+
+		iterator, err := collection.Where(filter.Equal("name", id.String())).DocumentIterator()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !iterator.Next() {
+			t.Fatalf("document %s not found", id)
+		}
+
+		if !iterator.ID().Equal(id) {
+			t.Fatalf("expected id %s, got %s", id, iterator.ID())
+		}
+
+		err = iterator.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var key Key
+
+	err = embedded.DataTo(&key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key.Tenant != "sea" {
+		t.Fatalf("unexpected embedded id %+v", key)
+	}
+
+	err = collection.Database.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,5 +1,20 @@
 package wrap
 
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lucacasonato/wrap/filter"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
 // Index used to index data
 type Index interface{}
 
@@ -10,4 +25,641 @@ var (
 	DescendingIndex Index = -1
 	// TextIndex indexes text
 	TextIndex Index = "text"
+	// GeoSphereIndex indexes GeoJSON objects and coordinate pairs on the surface of the earth
+	GeoSphereIndex Index = "2dsphere"
+	// GeoFlatIndex indexes legacy coordinate pairs on a flat plane
+	GeoFlatIndex Index = "2d"
+	// HashedIndex indexes the hash of the value, for example for hashed sharding
+	HashedIndex Index = "hashed"
 )
+
+// AllFields is the field of a wildcard index on all fields. Use "field.$**" for a wildcard index on all subfields of a field
+const AllFields = "$**"
+
+// IndexKey is a field of an index
+type IndexKey struct {
+	Field string
+	Kind  Index
+}
+
+// Collation specifies language specific rules for string comparison
+type Collation struct {
+	// Locale is the ICU locale, for example "en" or "fr_CA"
+	Locale string `bson:"locale,omitempty"`
+	// CaseLevel includes case comparison at strength 1 or 2
+	CaseLevel bool `bson:"caseLevel,omitempty"`
+	// CaseFirst sorts "upper" or "lower" case first
+	CaseFirst string `bson:"caseFirst,omitempty"`
+	// Strength is the level of comparison, from 1 (base characters only) to 5 (identical)
+	Strength int `bson:"strength,omitempty"`
+	// NumericOrdering compares numeric strings as numbers
+	NumericOrdering bool `bson:"numericOrdering,omitempty"`
+	// Alternate is "shifted" to ignore whitespace and punctuation or "non-ignorable"
+	Alternate string `bson:"alternate,omitempty"`
+	// MaxVariable is "punct" or "space" and sets which characters are ignored if Alternate is "shifted"
+	MaxVariable string `bson:"maxVariable,omitempty"`
+	// Normalization checks if text requires normalization
+	Normalization bool `bson:"normalization,omitempty"`
+	// Backwards sorts strings with diacritics from the back of the string
+	Backwards bool `bson:"backwards,omitempty"`
+}
+
+// IndexDefinition describes an index with its fields and options
+type IndexDefinition struct {
+	// Keys are the fields of the index in order
+	Keys []IndexKey
+	// Name of the index. If empty the default name is generated from the keys
+	Name string
+	// Unique rejects documents with a duplicate value for the keys
+	Unique bool
+	// Sparse only indexes documents that contain the keys
+	Sparse bool
+	// PartialFilter only indexes documents that match the filter
+	PartialFilter filter.Filter
+	// TTL deletes documents once the date in the key plus ExpireAfter has passed. Only for single key indexes
+	// on date fields. Indexes with a positive ExpireAfter are always TTL indexes, set TTL to delete documents
+	// at the date in the key itself
+	TTL bool
+	// ExpireAfter is the time after the date in the key at which documents of a TTL index are deleted. Must be
+	// a whole number of seconds
+	ExpireAfter time.Duration
+	// Collation is used for string comparison in the index
+	Collation *Collation
+	// Weights of the fields of a text index. Fields without a weight have weight 1
+	Weights map[string]int
+	// DefaultLanguage of a text index
+	DefaultLanguage string
+}
+
+// IndexPlan is the list of changes needed to bring the indexes of a collection in line with the declared indexes
+type IndexPlan struct {
+	// Create are the indexes that are created
+	Create []IndexDefinition
+	// Drop are the names of the indexes that are dropped
+	Drop []string
+	// Created are the names of the indexes EnsureIndexes created, also if it returned an error
+	Created []string
+	// Dropped are the names of the indexes EnsureIndexes dropped, also if it returned an error
+	Dropped []string
+}
+
+// CreateIndexes creates the indexes and returns their names
+func (c *Collection) CreateIndexes(indexes ...IndexDefinition) ([]string, error) {
+	if len(indexes) == 0 {
+		return []string{}, nil
+	}
+
+	models := make([]mongo.IndexModel, len(indexes))
+
+	for i, index := range indexes {
+		err := index.validate()
+		if err != nil {
+			return nil, err
+		}
+
+		models[i] = index.model()
+	}
+
+	ctx, cancel := c.Database.Client.ctx()
+	defer cancel()
+
+	names, err := c.collection.Indexes().CreateMany(ctx, models)
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	return names, nil
+}
+
+// ListIndexes returns all indexes of the collection
+func (c *Collection) ListIndexes() ([]IndexDefinition, error) {
+	ctx, cancel := c.Database.Client.ctx()
+	defer cancel()
+
+	cursor, err := c.collection.Indexes().List(ctx)
+	if err != nil {
+		return nil, convertError(err)
+	}
+	defer cursor.Close(ctx)
+
+	indexes := []IndexDefinition{}
+
+	for cursor.Next(ctx) {
+		index, err := indexFromSpec(cursor.Current)
+		if err != nil {
+			return nil, err
+		}
+
+		indexes = append(indexes, index)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	return indexes, nil
+}
+
+// DropIndex drops the index with the name
+func (c *Collection) DropIndex(name string) error {
+	ctx, cancel := c.Database.Client.ctx()
+	defer cancel()
+
+	_, err := c.collection.Indexes().DropOne(ctx, name)
+	if err != nil {
+		return convertError(err)
+	}
+
+	return nil
+}
+
+// DropAllIndexes drops all indexes except the index on _id
+func (c *Collection) DropAllIndexes() error {
+	ctx, cancel := c.Database.Client.ctx()
+	defer cancel()
+
+	_, err := c.collection.Indexes().DropAll(ctx)
+	if err != nil {
+		return convertError(err)
+	}
+
+	return nil
+}
+
+// PlanIndexes compares the declared indexes with the indexes on the server and returns the
+// changes EnsureIndexes would make, without making them
+func (c *Collection) PlanIndexes(indexes ...IndexDefinition) (*IndexPlan, error) {
+	plan, _, err := c.planIndexes(indexes, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// planIndexes returns the plan and the existing indexes it is based on. Undeclared indexes are only dropped if prune is true
+func (c *Collection) planIndexes(indexes []IndexDefinition, prune bool) (*IndexPlan, []IndexDefinition, error) {
+	for _, index := range indexes {
+		err := index.validate()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	existing, err := c.ListIndexes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plan := &IndexPlan{
+		Create:  []IndexDefinition{},
+		Drop:    []string{},
+		Created: []string{},
+		Dropped: []string{},
+	}
+
+	declared := map[string]bool{}
+
+	for _, declaredIndex := range indexes {
+		index := declaredIndex.normalize()
+		declared[index.Name] = true
+
+		// create the index as declared, the normalized keys may be in a different order
+		declaredIndex.Name = index.Name
+
+		found := false
+
+		for _, e := range existing {
+			if e.Name != index.Name {
+				continue
+			}
+
+			found = true
+
+			if !index.equal(e.normalize()) {
+				plan.Drop = append(plan.Drop, e.Name)
+				plan.Create = append(plan.Create, declaredIndex)
+			}
+		}
+
+		if !found {
+			plan.Create = append(plan.Create, declaredIndex)
+		}
+	}
+
+	for _, e := range existing {
+		if prune && e.Name != "_id_" && !declared[e.Name] {
+			plan.Drop = append(plan.Drop, e.Name)
+		}
+	}
+
+	return plan, existing, nil
+}
+
+// EnsureIndexes makes the indexes of the collection match the declared indexes. Indexes that
+// are not declared are dropped, except the index on _id. Indexes that are declared with
+// different options are dropped and created again. New indexes are created before any index
+// is dropped, so queries keep using the old indexes as long as possible. Returns the plan with
+// the changes that were made, also if an error is returned after some changes were made
+func (c *Collection) EnsureIndexes(indexes ...IndexDefinition) (*IndexPlan, error) {
+	return c.ensureIndexes(indexes, true)
+}
+
+func (c *Collection) ensureIndexes(indexes []IndexDefinition, prune bool) (*IndexPlan, error) {
+	plan, existing, err := c.planIndexes(indexes, prune)
+	if err != nil {
+		return nil, err
+	}
+
+	dropped := map[string]bool{}
+
+	for _, name := range plan.Drop {
+		dropped[name] = true
+	}
+
+	// indexes with the name or keys of an index that is dropped can only be created after the drop
+	create := []IndexDefinition{}
+	replace := []IndexDefinition{}
+
+	for _, index := range plan.Create {
+		normalized := index.normalize()
+		collides := false
+
+		for _, e := range existing {
+			if dropped[e.Name] && (e.Name == normalized.Name || normalized.sameKeys(e.normalize())) {
+				collides = true
+			}
+		}
+
+		if collides {
+			replace = append(replace, index)
+		} else {
+			create = append(create, index)
+		}
+	}
+
+	names, err := c.CreateIndexes(create...)
+	if err != nil {
+		return plan, err
+	}
+
+	plan.Created = append(plan.Created, names...)
+
+	for _, name := range plan.Drop {
+		err := c.DropIndex(name)
+		if err != nil {
+			return plan, err
+		}
+
+		plan.Dropped = append(plan.Dropped, name)
+	}
+
+	names, err = c.CreateIndexes(replace...)
+	if err != nil {
+		return plan, err
+	}
+
+	plan.Created = append(plan.Created, names...)
+
+	return plan, nil
+}
+
+// validate checks the options of the index that the server would otherwise change or reject
+func (index IndexDefinition) validate() error {
+	if index.ExpireAfter < 0 || index.ExpireAfter%time.Second != 0 {
+		return fmt.Errorf("wrap: ExpireAfter of index %s must be a whole number of seconds, got %s", index.normalize().Name, index.ExpireAfter)
+	}
+
+	return nil
+}
+
+func (index IndexDefinition) model() mongo.IndexModel {
+	keys := bson.D{}
+
+	for _, key := range index.Keys {
+		keys = append(keys, bson.E{Key: key.Field, Value: key.Kind})
+	}
+
+	opts := options.Index()
+
+	if index.Name != "" {
+		opts.SetName(index.Name)
+	}
+
+	if index.Unique {
+		opts.SetUnique(true)
+	}
+
+	if index.Sparse {
+		opts.SetSparse(true)
+	}
+
+	if index.PartialFilter != nil {
+		opts.SetPartialFilterExpression(index.PartialFilter)
+	}
+
+	if index.TTL || index.ExpireAfter > 0 {
+		opts.SetExpireAfterSeconds(int32(index.ExpireAfter / time.Second))
+	}
+
+	if index.Collation != nil {
+		collation := options.Collation(*index.Collation)
+		opts.SetCollation(&collation)
+	}
+
+	if len(index.Weights) > 0 {
+		opts.SetWeights(index.Weights)
+	}
+
+	if index.DefaultLanguage != "" {
+		opts.SetDefaultLanguage(index.DefaultLanguage)
+	}
+
+	return mongo.IndexModel{
+		Keys:    keys,
+		Options: opts,
+	}
+}
+
+// normalize fills in the defaults the server uses so indexes can be compared
+func (index IndexDefinition) normalize() IndexDefinition {
+	// the default name uses the keys in the declared order
+	index.TTL = index.TTL || index.ExpireAfter > 0
+
+	if index.Name == "" {
+		parts := []string{}
+
+		for _, key := range index.Keys {
+			parts = append(parts, key.Field, fmt.Sprint(key.Kind))
+		}
+
+		index.Name = strings.Join(parts, "_")
+	}
+
+	keys := []IndexKey{}
+	textFields := []string{}
+
+	for _, key := range index.Keys {
+		kind := normalizeIndexKind(key.Kind)
+
+		if kind == TextIndex {
+			textFields = append(textFields, key.Field)
+			continue
+		}
+
+		keys = append(keys, IndexKey{Field: key.Field, Kind: kind})
+	}
+
+	if len(textFields) > 0 {
+		sort.Strings(textFields)
+
+		weights := map[string]int{}
+
+		for _, field := range textFields {
+			keys = append(keys, IndexKey{Field: field, Kind: TextIndex})
+			weights[field] = 1
+		}
+
+		for field, weight := range index.Weights {
+			weights[field] = weight
+		}
+
+		index.Weights = weights
+
+		if index.DefaultLanguage == "" {
+			index.DefaultLanguage = "english"
+		}
+	}
+
+	index.Keys = keys
+
+	return index
+}
+
+// equal compares two normalized indexes
+func (index IndexDefinition) equal(other IndexDefinition) bool {
+	if index.Name != other.Name ||
+		index.Unique != other.Unique ||
+		index.Sparse != other.Sparse ||
+		index.TTL != other.TTL ||
+		index.ExpireAfter != other.ExpireAfter ||
+		index.DefaultLanguage != other.DefaultLanguage ||
+		!reflect.DeepEqual(index.Weights, other.Weights) ||
+		canonical(index.PartialFilter) != canonical(other.PartialFilter) ||
+		!index.sameKeys(other) {
+		return false
+	}
+
+	return collationMatches(index.Collation, other.Collation)
+}
+
+// sameKeys compares the keys of two normalized indexes
+func (index IndexDefinition) sameKeys(other IndexDefinition) bool {
+	if len(index.Keys) != len(other.Keys) {
+		return false
+	}
+
+	for i, key := range index.Keys {
+		if key.Field != other.Keys[i].Field || fmt.Sprint(key.Kind) != fmt.Sprint(other.Keys[i].Kind) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// collationMatches checks if all options set in the declared collation are the same in the existing collation
+func collationMatches(declared *Collation, existing *Collation) bool {
+	if declared == nil {
+		return existing == nil || existing.Locale == "simple"
+	}
+
+	if existing == nil {
+		return declared.Locale == "simple"
+	}
+
+	return declared.Locale == existing.Locale &&
+		(!declared.CaseLevel || existing.CaseLevel) &&
+		(declared.CaseFirst == "" || declared.CaseFirst == existing.CaseFirst) &&
+		(declared.Strength == 0 || declared.Strength == existing.Strength) &&
+		(!declared.NumericOrdering || existing.NumericOrdering) &&
+		(declared.Alternate == "" || declared.Alternate == existing.Alternate) &&
+		(declared.MaxVariable == "" || declared.MaxVariable == existing.MaxVariable) &&
+		(!declared.Normalization || existing.Normalization) &&
+		(!declared.Backwards || existing.Backwards)
+}
+
+func normalizeIndexKind(kind Index) Index {
+	switch k := kind.(type) {
+	case int, int32, int64, float64:
+		n, _ := strconv.ParseFloat(fmt.Sprint(k), 64)
+		if n < 0 {
+			return DescendingIndex
+		}
+
+		return AscendingIndex
+	case string:
+		return Index(k)
+	}
+
+	return kind
+}
+
+type indexSpec struct {
+	Name                    string        `bson:"name"`
+	Key                     bson.Raw      `bson:"key"`
+	Unique                  bool          `bson:"unique"`
+	Sparse                  bool          `bson:"sparse"`
+	PartialFilterExpression bson.M        `bson:"partialFilterExpression"`
+	ExpireAfterSeconds      bson.RawValue `bson:"expireAfterSeconds"`
+	Collation               *Collation    `bson:"collation"`
+	Weights                 bson.Raw      `bson:"weights"`
+	DefaultLanguage         string        `bson:"default_language"`
+}
+
+func indexFromSpec(raw bson.Raw) (IndexDefinition, error) {
+	var spec indexSpec
+
+	err := bson.Unmarshal(raw, &spec)
+	if err != nil {
+		return IndexDefinition{}, err
+	}
+
+	index := IndexDefinition{
+		Name:            spec.Name,
+		Unique:          spec.Unique,
+		Sparse:          spec.Sparse,
+		Collation:       spec.Collation,
+		DefaultLanguage: spec.DefaultLanguage,
+		Keys:            []IndexKey{},
+	}
+
+	if spec.PartialFilterExpression != nil {
+		index.PartialFilter = filter.Filter(&spec.PartialFilterExpression)
+	}
+
+	if seconds, ok := numberValue(spec.ExpireAfterSeconds); ok {
+		index.TTL = true
+		index.ExpireAfter = time.Duration(seconds) * time.Second
+	}
+
+	keys, err := spec.Key.Elements()
+	if err != nil {
+		return IndexDefinition{}, err
+	}
+
+	for _, key := range keys {
+		// text indexes store the text fields in the weights
+		if key.Key() == "_fts" || key.Key() == "_ftsx" {
+			continue
+		}
+
+		var kind Index = AscendingIndex
+
+		if n, ok := numberValue(key.Value()); ok {
+			if n < 0 {
+				kind = DescendingIndex
+			}
+		} else if s, ok := key.Value().StringValueOK(); ok {
+			kind = Index(s)
+		}
+
+		index.Keys = append(index.Keys, IndexKey{Field: key.Key(), Kind: kind})
+	}
+
+	if spec.Weights != nil {
+		weights, err := spec.Weights.Elements()
+		if err != nil {
+			return IndexDefinition{}, err
+		}
+
+		index.Weights = map[string]int{}
+
+		for _, weight := range weights {
+			n, _ := numberValue(weight.Value())
+
+			index.Weights[weight.Key()] = int(n)
+			index.Keys = append(index.Keys, IndexKey{Field: weight.Key(), Kind: TextIndex})
+		}
+	}
+
+	return index, nil
+}
+
+func numberValue(value bson.RawValue) (float64, bool) {
+	switch value.Type {
+	case bsontype.Int32:
+		return float64(value.Int32()), true
+	case bsontype.Int64:
+		return float64(value.Int64()), true
+	case bsontype.Double:
+		return value.Double(), true
+	}
+
+	return 0, false
+}
+
+// canonical returns a representation of a document that does not depend on the order of fields or the type of numbers
+func canonical(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		if v.IsNil() {
+			return ""
+		}
+	}
+
+	raw, err := bson.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return canonicalDocument(raw)
+}
+
+func canonicalDocument(raw bson.Raw) string {
+	elements, err := raw.Elements()
+	if err != nil {
+		return raw.String()
+	}
+
+	fields := []string{}
+
+	for _, element := range elements {
+		fields = append(fields, strconv.Quote(element.Key())+":"+canonicalValue(element.Value()))
+	}
+
+	sort.Strings(fields)
+
+	return "{" + strings.Join(fields, ",") + "}"
+}
+
+func canonicalValue(value bson.RawValue) string {
+	if n, ok := numberValue(value); ok {
+		return strconv.FormatFloat(n, 'g', -1, 64)
+	}
+
+	switch value.Type {
+	case bsontype.EmbeddedDocument:
+		return canonicalDocument(value.Document())
+	case bsontype.Array:
+		values, err := value.Array().Values()
+		if err != nil {
+			return value.String()
+		}
+
+		items := []string{}
+
+		for _, v := range values {
+			items = append(items, canonicalValue(v))
+		}
+
+		return "[" + strings.Join(items, ",") + "]"
+	}
+
+	return value.String()
+}
@@ -0,0 +1,328 @@
+package wrap
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IndexesFor derives index definitions from the wrap tags of a struct. The tag contains
+// comma separated options:
+//
+//	index=asc        index the field ascending (also desc, 2dsphere, 2d, hashed or wildcard)
+//	unique           reject duplicate values
+//	sparse           only index documents that contain the field
+//	ttl=24h          delete documents this long after the date in the field, ttl=0 at the date itself
+//	name=by_email    name of the index, not for fields in a group
+//	group=by_owner   add the field to the compound index with this name, in field order
+//	text             add the field to the text index of the collection
+//	weight=5         weight of the field in the text index
+//	language=dutch   default language of the text index
+//
+// Field names are taken from the bson tag. Fields of embedded structs are indexed with a dotted path
+func IndexesFor(v interface{}) ([]IndexDefinition, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("wrap: can not derive indexes from %T, expected a struct", v)
+	}
+
+	builder := &indexBuilder{
+		groups: map[string]int{},
+	}
+
+	err := builder.addStruct(t, "", map[reflect.Type]bool{})
+	if err != nil {
+		return nil, err
+	}
+
+	if builder.text != nil {
+		builder.indexes = append(builder.indexes, *builder.text)
+	}
+
+	return builder.indexes, nil
+}
+
+// PlanIndexesFor returns the changes EnsureIndexesFor would make, without making them
+func (c *Collection) PlanIndexesFor(v interface{}, prune bool) (*IndexPlan, error) {
+	indexes, err := IndexesFor(v)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, _, err := c.planIndexes(indexes, prune)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// EnsureIndexesFor creates the indexes declared in the wrap tags of a struct and recreates declared
+// indexes whose options changed. Indexes that are not declared in the struct, for example indexes
+// created by hand, are only dropped if prune is true
+func (c *Collection) EnsureIndexesFor(v interface{}, prune bool) (*IndexPlan, error) {
+	indexes, err := IndexesFor(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.ensureIndexes(indexes, prune)
+}
+
+type indexBuilder struct {
+	indexes []IndexDefinition
+	// groups maps the name of a compound index to its position in indexes
+	groups map[string]int
+	text   *IndexDefinition
+}
+
+func (b *indexBuilder) addStruct(t reflect.Type, prefix string, visiting map[reflect.Type]bool) error {
+	if visiting[t] {
+		return nil
+	}
+
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name, inline, skip := bsonFieldName(field)
+		if skip {
+			continue
+		}
+
+		path := prefix + name
+		if inline {
+			path = strings.TrimSuffix(prefix, ".")
+		}
+
+		tag, ok := field.Tag.Lookup("wrap")
+		if !ok || tag == "" {
+			ft := field.Type
+			for ft.Kind() == reflect.Ptr || ft.Kind() == reflect.Slice || ft.Kind() == reflect.Array {
+				ft = ft.Elem()
+			}
+
+			if ft.Kind() == reflect.Struct {
+				nested := path + "."
+				if path == "" {
+					nested = ""
+				}
+
+				err := b.addStruct(ft, nested, visiting)
+				if err != nil {
+					return err
+				}
+			}
+
+			continue
+		}
+
+		if tag == "-" {
+			continue
+		}
+
+		err := b.addField(path, field.Name, tag)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *indexBuilder) addField(path, fieldName, tag string) error {
+	var (
+		kind     Index
+		index    IndexDefinition
+		group    string
+		text     bool
+		weight   int
+		language string
+	)
+
+	for _, option := range strings.Split(tag, ",") {
+		key, value := option, ""
+		if i := strings.Index(option, "="); i >= 0 {
+			key, value = option[:i], option[i+1:]
+		}
+
+		switch strings.TrimSpace(key) {
+		case "index":
+			k, err := indexKind(value)
+			if err != nil {
+				return fmt.Errorf("wrap: invalid index tag on field %s: %v", fieldName, err)
+			}
+			kind = k
+		case "unique":
+			index.Unique = true
+		case "sparse":
+			index.Sparse = true
+		case "ttl":
+			d, err := parseTTL(value)
+			if err != nil {
+				return fmt.Errorf("wrap: invalid index tag on field %s: %v", fieldName, err)
+			}
+			index.TTL = true
+			index.ExpireAfter = d
+		case "name":
+			index.Name = value
+		case "group":
+			group = value
+		case "text":
+			text = true
+		case "weight":
+			w, err := strconv.Atoi(value)
+			if err != nil || w < 1 {
+				return fmt.Errorf("wrap: invalid index tag on field %s: weight %q is not a positive number", fieldName, value)
+			}
+			text = true
+			weight = w
+		case "language":
+			text = true
+			language = value
+		default:
+			return fmt.Errorf("wrap: invalid index tag on field %s: unknown option %q", fieldName, key)
+		}
+	}
+
+	if path == "" {
+		return fmt.Errorf("wrap: invalid index tag on field %s: inline fields can not be indexed", fieldName)
+	}
+
+	if text {
+		if kind != nil {
+			return fmt.Errorf("wrap: invalid index tag on field %s: a field can not be in a text index and a regular index", fieldName)
+		}
+
+		b.addText(path, weight, language)
+
+		return nil
+	}
+
+	if kind == nil {
+		kind = AscendingIndex
+	}
+
+	if kind == AllFields {
+		path += "." + AllFields
+		kind = AscendingIndex
+	}
+
+	key := IndexKey{Field: path, Kind: kind}
+
+	if group == "" {
+		index.Keys = []IndexKey{key}
+		b.indexes = append(b.indexes, index)
+
+		return nil
+	}
+
+	if index.TTL {
+		return fmt.Errorf("wrap: invalid index tag on field %s: ttl can not be used in compound index %s", fieldName, group)
+	}
+
+	if index.Name != "" {
+		return fmt.Errorf("wrap: invalid index tag on field %s: name can not be used in compound index %s, the index is named after the group", fieldName, group)
+	}
+
+	i, ok := b.groups[group]
+	if !ok {
+		b.groups[group] = len(b.indexes)
+		b.indexes = append(b.indexes, IndexDefinition{Name: group})
+		i = len(b.indexes) - 1
+	}
+
+	grouped := &b.indexes[i]
+	grouped.Keys = append(grouped.Keys, key)
+	grouped.Unique = grouped.Unique || index.Unique
+	grouped.Sparse = grouped.Sparse || index.Sparse
+
+	return nil
+}
+
+func (b *indexBuilder) addText(path string, weight int, language string) {
+	if b.text == nil {
+		b.text = &IndexDefinition{}
+	}
+
+	b.text.Keys = append(b.text.Keys, IndexKey{Field: path, Kind: TextIndex})
+
+	if weight > 0 {
+		if b.text.Weights == nil {
+			b.text.Weights = map[string]int{}
+		}
+
+		b.text.Weights[path] = weight
+	}
+
+	if language != "" {
+		b.text.DefaultLanguage = language
+	}
+}
+
+// indexKind converts the value of the index option to an index kind
+func indexKind(value string) (Index, error) {
+	switch value {
+	case "", "asc", "1":
+		return AscendingIndex, nil
+	case "desc", "-1":
+		return DescendingIndex, nil
+	case "2dsphere":
+		return GeoSphereIndex, nil
+	case "2d":
+		return GeoFlatIndex, nil
+	case "hashed":
+		return HashedIndex, nil
+	case "wildcard":
+		return AllFields, nil
+	}
+
+	return nil, fmt.Errorf("unknown index kind %q", value)
+}
+
+// parseTTL parses a duration like "24h" or a number of seconds
+func parseTTL(value string) (time.Duration, error) {
+	seconds, err := strconv.Atoi(value)
+	if err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("ttl %q is not a duration", value)
+	}
+
+	return d, nil
+}
+
+// bsonFieldName returns the name the bson encoder uses for a field
+func bsonFieldName(field reflect.StructField) (name string, inline bool, skip bool) {
+	tag := field.Tag.Get("bson")
+	if tag == "-" {
+		return "", false, true
+	}
+
+	parts := strings.Split(tag, ",")
+	for _, option := range parts[1:] {
+		if option == "inline" {
+			return "", true, false
+		}
+	}
+
+	if parts[0] != "" {
+		return parts[0], false, false
+	}
+
+	return strings.ToLower(field.Name), false, false
+}
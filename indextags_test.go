@@ -0,0 +1,158 @@
+package wrap_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lucacasonato/wrap"
+	"github.com/lucacasonato/wrap/geo"
+)
+
+// Account with indexes declared in its tags
+type Account struct {
+	Email    string    `bson:"email" wrap:"index=asc,unique"`
+	Owner    string    `bson:"owner" wrap:"index=asc,group=owner_created"`
+	Created  time.Time `bson:"created" wrap:"index=desc,group=owner_created"`
+	Session  time.Time `bson:"session" wrap:"ttl=1h"`
+	Name     string    `bson:"name" wrap:"text,weight=5"`
+	Bio      string    `bson:"bio" wrap:"text"`
+	Address  Address   `bson:"address"`
+	Settings Settings  `bson:"settings" wrap:"index=wildcard"`
+	Ignored  string    `bson:"ignored"`
+}
+
+// Address of an account
+type Address struct {
+	City     string    `bson:"city" wrap:"index=hashed"`
+	Location geo.Point `bson:"location" wrap:"index=2dsphere,sparse"`
+}
+
+// Settings of an account
+type Settings map[string]interface{}
+
+func TestIndexesFor(t *testing.T) {
+	indexes, err := wrap.IndexesFor(&Account{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []wrap.IndexDefinition{
+		{Keys: []wrap.IndexKey{{Field: "email", Kind: wrap.AscendingIndex}}, Unique: true},
+		{Name: "owner_created", Keys: []wrap.IndexKey{
+			{Field: "owner", Kind: wrap.AscendingIndex},
+			{Field: "created", Kind: wrap.DescendingIndex},
+		}},
+		{Keys: []wrap.IndexKey{{Field: "session", Kind: wrap.AscendingIndex}}, TTL: true, ExpireAfter: time.Hour},
+		{Keys: []wrap.IndexKey{{Field: "address.city", Kind: wrap.HashedIndex}}},
+		{Keys: []wrap.IndexKey{{Field: "address.location", Kind: wrap.GeoSphereIndex}}, Sparse: true},
+		{Keys: []wrap.IndexKey{{Field: "settings.$**", Kind: wrap.AscendingIndex}}},
+		{Keys: []wrap.IndexKey{
+			{Field: "name", Kind: wrap.TextIndex},
+			{Field: "bio", Kind: wrap.TextIndex},
+		}, Weights: map[string]int{"name": 5}},
+	}
+
+	if !reflect.DeepEqual(indexes, expected) {
+		t.Fatalf("unexpected indexes\n%+v\nexpected\n%+v", indexes, expected)
+	}
+}
+
+func TestIndexesForTTL(t *testing.T) {
+	indexes, err := wrap.IndexesFor(&struct {
+		Expires time.Time `bson:"expires" wrap:"ttl=0"`
+	}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(indexes) != 1 || !indexes[0].TTL || indexes[0].ExpireAfter != 0 {
+		t.Fatalf("expected a TTL index that expires at the date, got %+v", indexes)
+	}
+}
+
+func TestIndexesForInvalid(t *testing.T) {
+	for _, v := range []interface{}{
+		"not a struct",
+		&struct {
+			Field string `wrap:"index=sideways"`
+		}{},
+		&struct {
+			Field string `wrap:"text,weight=heavy"`
+		}{},
+		&struct {
+			Field string `wrap:"indexed"`
+		}{},
+		&struct {
+			Field string `wrap:"index=asc,text"`
+		}{},
+		&struct {
+			First  string `wrap:"group=pair,name=by_first"`
+			Second string `wrap:"group=pair"`
+		}{},
+		&struct {
+			First  time.Time `wrap:"group=pair,ttl=0"`
+			Second string    `wrap:"group=pair"`
+		}{},
+	} {
+		_, err := wrap.IndexesFor(v)
+		if err == nil {
+			t.Fatalf("expected an error for %T", v)
+		}
+	}
+}
+
+func TestCollectionEnsureIndexesFor(t *testing.T) {
+	collection, err := createCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer collection.Database.Delete()
+
+	accounts := collection.Database.Collection("accounts")
+
+	err = accounts.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = accounts.CreateIndexes(wrap.IndexDefinition{
+		Keys: []wrap.IndexKey{{Field: "ignored", Kind: wrap.AscendingIndex}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := accounts.PlanIndexesFor(&Account{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plan.Create) != 7 || len(plan.Drop) != 0 {
+		t.Fatalf("expected 7 planned indexes, got %+v", plan)
+	}
+
+	_, err = accounts.EnsureIndexesFor(&Account{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err = accounts.PlanIndexesFor(&Account{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plan.Create) != 0 || len(plan.Drop) != 0 {
+		t.Fatalf("expected no changes, got %+v", plan)
+	}
+
+	plan, err = accounts.EnsureIndexesFor(&Account{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plan.Drop) != 1 || plan.Drop[0] != "ignored_1" {
+		t.Fatalf("expected the undeclared index to be dropped, got %+v", plan)
+	}
+}
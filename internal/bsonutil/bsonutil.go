@@ -0,0 +1,145 @@
+// Package bsonutil contains helpers for the bson documents built by the filter and update packages
+package bsonutil
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// SortedKeys returns the keys of the document in sorted order
+func SortedKeys(doc bson.M) []string {
+	keys := []string{}
+
+	for key := range doc {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Invalid is a value that can not be encoded. Queries and updates that contain it fail with the error
+type Invalid struct {
+	Err error
+}
+
+// MarshalBSON returns the error of the invalid value
+func (i Invalid) MarshalBSON() ([]byte, error) {
+	return nil, i.Err
+}
+
+// ValueCondition merges the filters with an empty field in a filter into one document of operators, for
+// example {"": {"$gt": 1}} and {"$and": [{"": {"$gt": 1}}, {"": {"$lt": 5}}]}. values is false if the
+// filter does not contain filters with an empty field. An error is returned if they are combined with
+// filters on fields or with $or or $nor, or if an operator is used more than once
+func ValueCondition(filter bson.M) (operators bson.D, values bool, err error) {
+	merged := bson.M{}
+	fields := false
+
+	merge := func(condition bson.D) error {
+		for _, e := range condition {
+			if _, ok := merged[e.Key]; ok {
+				return fmt.Errorf("%s is used more than once", e.Key)
+			}
+
+			merged[e.Key] = e.Value
+		}
+
+		return nil
+	}
+
+	for _, key := range SortedKeys(filter) {
+		value := filter[key]
+
+		switch key {
+		case "":
+			values = true
+
+			ops, ok := value.(bson.M)
+			if !ok {
+				ops = bson.M{"$eq": value}
+			}
+
+			condition := bson.D{}
+			for _, operator := range SortedKeys(ops) {
+				condition = append(condition, bson.E{Key: operator, Value: ops[operator]})
+			}
+
+			err := merge(condition)
+			if err != nil {
+				return nil, false, err
+			}
+		case "$and", "$or", "$nor":
+			for _, sub := range documents(value) {
+				condition, subValues, err := ValueCondition(sub)
+				if err != nil {
+					return nil, false, err
+				}
+
+				if !subValues {
+					fields = true
+					continue
+				}
+
+				if key != "$and" {
+					return nil, false, fmt.Errorf("filters with an empty field can not be combined with %s", key)
+				}
+
+				values = true
+
+				err = merge(condition)
+				if err != nil {
+					return nil, false, err
+				}
+			}
+		default:
+			fields = true
+		}
+	}
+
+	if values && fields {
+		return nil, false, fmt.Errorf("filters with an empty field can not be combined with filters on fields")
+	}
+
+	for _, operator := range SortedKeys(merged) {
+		operators = append(operators, bson.E{Key: operator, Value: merged[operator]})
+	}
+
+	return operators, values, nil
+}
+
+// documents returns the documents in a list of filters. The filters are pointers to bson.M, but can be of
+// a named type like filter.Filter
+func documents(value interface{}) []bson.M {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil
+	}
+
+	docs := []bson.M{}
+
+	for i := 0; i < v.Len(); i++ {
+		item := v.Index(i)
+		if item.Kind() == reflect.Interface {
+			item = item.Elem()
+		}
+
+		if item.Kind() == reflect.Ptr {
+			if item.IsNil() {
+				continue
+			}
+
+			item = item.Elem()
+		}
+
+		if doc, ok := item.Interface().(bson.M); ok {
+			docs = append(docs, doc)
+		}
+	}
+
+	return docs
+}
@@ -2,7 +2,10 @@ package wrap
 
 // Next means go to the next document in the iterator
 func (i *Iterator) Next() bool {
-	return i.cursor.Next(i.Collection.Database.Client.ctx())
+	ctx, cancel := i.Collection.Database.Client.ctx()
+	defer cancel()
+
+	return i.cursor.Next(ctx)
 }
 
 // Data decodes some data and returns an interface
@@ -10,7 +13,7 @@ func (i *Iterator) Data() (interface{}, error) {
 	var data interface{}
 	err := i.cursor.Decode(&data)
 	if err != nil {
-		return nil, err
+		return nil, convertError(err)
 	}
 
 	return data, nil
@@ -20,28 +23,43 @@ func (i *Iterator) Data() (interface{}, error) {
 func (i *Iterator) DataTo(data interface{}) error {
 	err := i.cursor.Decode(data)
 	if err != nil {
-		return err
+		return convertError(err)
 	}
 
 	return nil
 }
 
-// ID gets the ID of the current iterator item
-func (i *Iterator) ID() string {
-	objectID, err := i.cursor.Current.LookupErr("_id")
+// ID gets the ID of the current iterator item. The ID is zero if the item has no ID
+func (i *Iterator) ID() ID {
+	var id ID
+
+	value, err := i.cursor.Current.LookupErr("_id")
+	if err != nil {
+		return id
+	}
+
+	err = id.UnmarshalBSONValue(value.Type, value.Value)
 	if err != nil {
-		return ""
+		return ID{}
 	}
 
-	return objectID.ObjectID().Hex()
+	return id
 }
 
 // Close stops the iterator
 func (i *Iterator) Close() error {
-	err := i.cursor.Close(i.Collection.Database.Client.ctx())
+	ctx, cancel := i.Collection.Database.Client.ctx()
+	defer cancel()
+
+	err := i.cursor.Close(ctx)
 	if err != nil {
-		return err
+		return convertError(err)
 	}
 
 	return nil
 }
+
+// Err returns the error that stopped the iterator, if any
+func (i *Iterator) Err() error {
+	return convertError(i.cursor.Err())
+}
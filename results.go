@@ -0,0 +1,96 @@
+package wrap
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// UpdateResult is the result of an update or set operation
+type UpdateResult struct {
+	// Matched is the amount of documents that matched the filter
+	Matched int64
+	// Modified is the amount of documents that were changed
+	Modified int64
+	// Upserted is the document that was created by the operation. nil if no document was created
+	Upserted *Document
+}
+
+// DeleteResult is the result of a delete operation
+type DeleteResult struct {
+	// Deleted is the amount of documents that were deleted
+	Deleted int64
+}
+
+// OutputResult is the result of a query that writes its documents to a collection
+type OutputResult struct {
+	// Collection is the collection the documents were written to
+	Collection *Collection
+	// Duration is the time it took to run the query
+	Duration time.Duration
+}
+
+// BulkResult is the result of a bulk write
+type BulkResult struct {
+	// Inserted is the amount of documents that were inserted
+	Inserted int64
+	// Matched is the amount of documents that matched the filters of update and set operations
+	Matched int64
+	// Modified is the amount of documents that were changed by update and set operations
+	Modified int64
+	// Deleted is the amount of documents that were deleted
+	Deleted int64
+	// UpsertedCount is the amount of documents that were created by update and set operations
+	UpsertedCount int64
+	// InsertedIDs are the documents that were inserted by Add calls in the order of the operations.
+	// Failed inserts and inserts that were not sent to the server are left out
+	InsertedIDs []*Document
+	// Upserted are the documents that were created by update and set operations by the index of the operation
+	Upserted map[int]*Document
+	// Errors are the operations that failed
+	Errors []*BulkError
+	// NotExecuted is the amount of operations that were not executed because an earlier operation stopped the bulk write
+	NotExecuted int
+}
+
+// BulkError is the error of a single operation in a bulk write
+type BulkError struct {
+	// Index of the operation in the order the operations were queued
+	Index int
+	// Err is the reason the operation failed
+	Err error
+}
+
+func (e *BulkError) Error() string {
+	return fmt.Sprintf("operation %d: %s", e.Index, e.Err.Error())
+}
+
+// Unwrap returns the reason the operation failed
+func (e *BulkError) Unwrap() error {
+	return e.Err
+}
+
+func (c *Collection) updateResult(res *mongo.UpdateResult) (*UpdateResult, error) {
+	result := &UpdateResult{
+		Matched:  res.MatchedCount,
+		Modified: res.ModifiedCount,
+	}
+
+	if res.UpsertedID != nil {
+		doc, err := c.documentFromID(res.UpsertedID)
+		if err != nil {
+			return result, err
+		}
+
+		result.Upserted = doc
+	}
+
+	return result, nil
+}
+
+func deleteResult(res *mongo.DeleteResult) *DeleteResult {
+	return &DeleteResult{
+		Deleted: res.DeletedCount,
+	}
+}
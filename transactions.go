@@ -8,13 +8,13 @@ import (
 func (c *Client) Transaction(run func(client *Client) error) error {
 	session, err := c.client.StartSession()
 	if err != nil {
-		return err
+		return convertError(err)
 	}
 	defer session.EndSession(c.context)
 
 	err = session.StartTransaction()
 	if err != nil {
-		return err
+		return convertError(err)
 	}
 
 	err = mongo.WithSession(c.context, session, func(sc mongo.SessionContext) error {
@@ -31,7 +31,7 @@ func (c *Client) Transaction(run func(client *Client) error) error {
 
 		err = session.CommitTransaction(sc)
 		if err != nil {
-			return err
+			return convertError(err)
 		}
 
 		return nil
@@ -0,0 +1,131 @@
+package update
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lucacasonato/wrap/internal/bsonutil"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ConflictError is returned by Combine if two updates change the same field, or a field and one of its subfields
+type ConflictError struct {
+	// Operator and Field of the first update
+	Operator string
+	Field    string
+	// OtherOperator and OtherField of the update that conflicts with the first update
+	OtherOperator string
+	OtherField    string
+}
+
+func (e *ConflictError) Error() string {
+	if e.Field == e.OtherField {
+		return fmt.Sprintf("update: %s and %s both update field %q", e.Operator, e.OtherOperator, e.Field)
+	}
+
+	return fmt.Sprintf("update: %s of field %q conflicts with %s of field %q", e.Operator, e.Field, e.OtherOperator, e.OtherField)
+}
+
+type updatedField struct {
+	operator string
+	path     []string
+}
+
+// Combine merges updates into a single update. Fields keep the order of the updates. An error is
+// returned if two updates change the same field, or a field and one of its subfields, because only one of them
+// would be applied. ArrayFilters are left out, use ArrayFiltersOf to get them
+func Combine(updates ...Update) (Update, error) {
+	combined := bson.M{}
+	fields := []updatedField{}
+
+	for _, u := range updates {
+		if u == nil {
+			continue
+		}
+
+		for _, operator := range bsonutil.SortedKeys(*u) {
+			if operator == arrayFiltersKey {
+				continue
+			}
+
+			values, err := operatorFields(operator, (*u)[operator])
+			if err != nil {
+				return nil, err
+			}
+
+			if len(values) == 0 {
+				continue
+			}
+
+			for _, value := range values {
+				paths := []string{value.Key}
+
+				// $rename also changes the field it renames to
+				if name, ok := value.Value.(string); ok && operator == "$rename" {
+					paths = append(paths, name)
+				}
+
+				for _, path := range paths {
+					field := updatedField{operator: operator, path: strings.Split(path, ".")}
+
+					for _, other := range fields {
+						if overlaps(field.path, other.path) {
+							return nil, &ConflictError{
+								Operator:      other.operator,
+								Field:         strings.Join(other.path, "."),
+								OtherOperator: operator,
+								OtherField:    path,
+							}
+						}
+					}
+
+					fields = append(fields, field)
+				}
+			}
+
+			existing, _ := combined[operator].(bson.D)
+			combined[operator] = append(existing, values...)
+		}
+	}
+
+	if len(combined) == 0 {
+		return nil, fmt.Errorf("update: no updates")
+	}
+
+	return Update(&combined), nil
+}
+
+// operatorFields returns the fields of an update operator in a stable order
+func operatorFields(operator string, value interface{}) (bson.D, error) {
+	switch v := value.(type) {
+	case bson.M:
+		d := bson.D{}
+
+		for _, key := range bsonutil.SortedKeys(v) {
+			d = append(d, bson.E{Key: key, Value: v[key]})
+		}
+
+		return d, nil
+	case map[string]interface{}:
+		return operatorFields(operator, bson.M(v))
+	case bson.D:
+		return v, nil
+	}
+
+	return nil, fmt.Errorf("update: %s must be a document of fields, got %T", operator, value)
+}
+
+// overlaps checks if the paths are the same or one is a parent of the other
+func overlaps(a []string, b []string) bool {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
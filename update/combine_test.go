@@ -0,0 +1,97 @@
+package update_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lucacasonato/wrap/update"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCombine(t *testing.T) {
+	combined, err := update.Combine(
+		update.Set("b", 1),
+		update.Set("a", 2),
+		nil,
+		update.Increment("count", 1),
+		update.BitAND("flags", 1),
+		update.Set("c.d", 3),
+		update.Set("c.e", 4),
+		update.Rename("old", "new"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := bson.Marshal(combined)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, expected := range map[string]string{
+		"$set":    `{"b": {"$numberInt":"1"},"a": {"$numberInt":"2"},"c.d": {"$numberInt":"3"},"c.e": {"$numberInt":"4"}}`,
+		"$inc":    `{"count": {"$numberDouble":"1.0"}}`,
+		"$bit":    `{"flags": {"and": {"$numberInt":"1"}}}`,
+		"$rename": `{"old": "new"}`,
+	} {
+		value, err := bson.Raw(raw).LookupErr(path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+
+		if value.String() != expected {
+			t.Errorf("%s: expected %s, got %s", path, expected, value)
+		}
+	}
+}
+
+func TestCombineConflict(t *testing.T) {
+	for _, updates := range [][]update.Update{
+		{update.Set("a", 1), update.Set("a", 2)},
+		{update.BitAND("a", 1), update.BitOR("a", 2)},
+		{update.Set("a", 1), update.Increment("a", 1)},
+		{update.Set("a", 1), update.Set("a.b", 1)},
+		{update.Unset("a.b.c"), update.Set("a.b", 1)},
+		{update.Rename("a", "b"), update.Set("b", 1)},
+		{update.Push("a", nil), update.PopFirst("a")},
+	} {
+		_, err := update.Combine(updates...)
+
+		var conflict *update.ConflictError
+		if !errors.As(err, &conflict) {
+			t.Fatalf("expected a conflict error, got %v", err)
+		}
+
+		t.Log(err)
+	}
+
+	for _, updates := range [][]update.Update{
+		{update.Set("a", 1), update.Set("ab", 1)},
+		{update.Set("a.b", 1), update.Set("a.c", 1)},
+		{update.Set("a.0", 1), update.Set("a.1", 1)},
+	} {
+		_, err := update.Combine(updates...)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := update.Combine()
+	if err == nil {
+		t.Fatal("expected an error without updates")
+	}
+}
+
+func TestCombineUnset(t *testing.T) {
+	_, err := update.Combine(update.Unset("a", "b"), update.Set("a.c", 1))
+
+	var conflict *update.ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("expected a conflict error, got %v", err)
+	}
+
+	_, err = update.Combine(update.Unset())
+	if err == nil {
+		t.Fatal("expected an error without fields")
+	}
+}
@@ -0,0 +1,60 @@
+package update
+
+import (
+	"strings"
+
+	"github.com/lucacasonato/wrap/filter"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// arrayFiltersKey is the key of the array filters in an update. It is not an update operator, Combine leaves it out
+const arrayFiltersKey = "$arrayFilters"
+
+// Positional returns the path of the first array item that matched the query of the update, for example
+// Set(Positional("items", "quantity"), 2) sets the quantity of the matched item. The query must filter on the array
+func Positional(array string, subfields ...string) string {
+	return path(array, "$", subfields)
+}
+
+// AllPositional returns the path of all items of the array, for example Increment(AllPositional("items", "quantity"), 1)
+// increments the quantity of all items
+func AllPositional(array string, subfields ...string) string {
+	return path(array, "$[]", subfields)
+}
+
+// FilteredPositional returns the path of the array items that match the array filter of the identifier, for example
+// Set(FilteredPositional("items", "item", "quantity"), 0) with the array filter filter.Equal("item.sku", "abc").
+// Identifiers start with a lowercase letter and contain only letters and numbers
+func FilteredPositional(array string, identifier string, subfields ...string) string {
+	return path(array, "$["+identifier+"]", subfields)
+}
+
+// ArrayFilters selects the array items that are updated through FilteredPositional paths. It is passed with
+// the other updates of the same call and only applies to that call. Every filter is on a field of one
+// identifier, for example filter.Equal("item.sku", "abc") for the identifier "item"
+func ArrayFilters(filters ...filter.Filter) Update {
+	return Update(&bson.M{
+		arrayFiltersKey: filters,
+	})
+}
+
+// ArrayFiltersOf returns the filters of all ArrayFilters in the updates
+func ArrayFiltersOf(updates ...Update) []filter.Filter {
+	filters := []filter.Filter{}
+
+	for _, u := range updates {
+		if u == nil {
+			continue
+		}
+
+		if f, ok := (*u)[arrayFiltersKey].([]filter.Filter); ok {
+			filters = append(filters, f...)
+		}
+	}
+
+	return filters
+}
+
+func path(array string, positional string, subfields []string) string {
+	return strings.Join(append([]string{array, positional}, subfields...), ".")
+}
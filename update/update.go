@@ -1,6 +1,12 @@
 package update
 
-import "go.mongodb.org/mongo-driver/bson"
+import (
+	"fmt"
+
+	"github.com/lucacasonato/wrap/filter"
+	"github.com/lucacasonato/wrap/internal/bsonutil"
+	"go.mongodb.org/mongo-driver/bson"
+)
 
 // Update fields
 type Update *bson.M
@@ -89,12 +95,16 @@ func SetIfNew(field string, value interface{}) Update {
 	})
 }
 
-// Unset removes a field from the document
-func Unset(field string) Update {
+// Unset removes the fields from the document
+func Unset(fields ...string) Update {
+	unset := bson.M{}
+
+	for _, field := range fields {
+		unset[field] = ""
+	}
+
 	return Update(&bson.M{
-		"$unset": bson.M{
-			field: "",
-		},
+		"$unset": unset,
 	})
 }
 
@@ -107,6 +117,21 @@ func AddToSet(field string, value interface{}) Update {
 	})
 }
 
+// AddAllToSet adds the values to the array that aren't already in the array
+func AddAllToSet(field string, values ...interface{}) Update {
+	if values == nil {
+		values = []interface{}{}
+	}
+
+	return Update(&bson.M{
+		"$addToSet": bson.M{
+			field: bson.M{
+				"$each": values,
+			},
+		},
+	})
+}
+
 // PopFirst removes the first element from the array
 func PopFirst(field string) Update {
 	return Update(&bson.M{
@@ -125,6 +150,29 @@ func PopLast(field string) Update {
 	})
 }
 
+// Pull removes all items from the array that are equal to the value or match the filter. For arrays of documents
+// use filters on the fields of the items, for example filter.Equal("sku", "abc"). For arrays of values use
+// filters with an empty field, for example filter.GreaterThan("", 5). Filters with an empty field can be
+// combined with filter.AND, but not with filter.OR or filters on fields. Running an update with such a Pull
+// returns an error
+func Pull(field string, condition interface{}) Update {
+	if f, ok := condition.(filter.Filter); ok && f != nil {
+		operators, values, err := bsonutil.ValueCondition(*f)
+
+		if err != nil {
+			condition = bsonutil.Invalid{Err: fmt.Errorf("update: Pull of %s: %v", field, err)}
+		} else if values {
+			condition = operators
+		}
+	}
+
+	return Update(&bson.M{
+		"$pull": bson.M{
+			field: condition,
+		},
+	})
+}
+
 // RemoveAll element(s) from an array that are in remove array
 func RemoveAll(field string, array []interface{}) Update {
 	return Update(&bson.M{
@@ -134,13 +182,98 @@ func RemoveAll(field string, array []interface{}) Update {
 	})
 }
 
+// PushOption modifies how items are pushed onto an array
+type PushOption func(modifiers *pushModifiers)
+
+type pushModifiers struct {
+	position *int
+	slice    *int
+	sort     interface{}
+}
+
+// Position inserts the items at the index instead of at the end of the array. A negative index counts from the end
+func Position(index int) PushOption {
+	return func(modifiers *pushModifiers) {
+		modifiers.position = &index
+	}
+}
+
+// Slice limits the array to the first n items after the push, or the last n items if n is negative
+func Slice(n int) PushOption {
+	return func(modifiers *pushModifiers) {
+		modifiers.slice = &n
+	}
+}
+
+// SortValues sorts an array of values after the push
+func SortValues(ascending bool) PushOption {
+	return func(modifiers *pushModifiers) {
+		modifiers.sort = direction(ascending)
+	}
+}
+
+// SortByField sorts an array of documents by the field after the push. Use multiple times to sort by multiple fields
+func SortByField(field string, ascending bool) PushOption {
+	return func(modifiers *pushModifiers) {
+		sort, ok := modifiers.sort.(bson.D)
+		if !ok {
+			sort = bson.D{}
+		}
+
+		modifiers.sort = append(sort, bson.E{Key: field, Value: direction(ascending)})
+	}
+}
+
+func direction(ascending bool) int {
+	if ascending {
+		return 1
+	}
+
+	return -1
+}
+
 // Push all elements onto the array
-func Push(field string, newItems []interface{}) Update {
+func Push(field string, newItems []interface{}, opts ...PushOption) Update {
+	modifiers := pushModifiers{}
+
+	for _, opt := range opts {
+		opt(&modifiers)
+	}
+
+	if newItems == nil {
+		newItems = []interface{}{}
+	}
+
+	push := bson.D{{Key: "$each", Value: newItems}}
+
+	if modifiers.position != nil {
+		push = append(push, bson.E{Key: "$position", Value: *modifiers.position})
+	}
+
+	if modifiers.slice != nil {
+		push = append(push, bson.E{Key: "$slice", Value: *modifiers.slice})
+	}
+
+	if modifiers.sort != nil {
+		push = append(push, bson.E{Key: "$sort", Value: modifiers.sort})
+	}
+
 	return Update(&bson.M{
 		"$push": bson.M{
-			"$each": bson.M{
-				field: newItems,
-			},
+			field: push,
+		},
+	})
+}
+
+// PushOne pushes a single item onto the array
+func PushOne(field string, item interface{}, opts ...PushOption) Update {
+	if len(opts) > 0 {
+		return Push(field, []interface{}{item}, opts...)
+	}
+
+	return Update(&bson.M{
+		"$push": bson.M{
+			field: item,
 		},
 	})
 }
@@ -0,0 +1,174 @@
+package update_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lucacasonato/wrap/filter"
+	"github.com/lucacasonato/wrap/update"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestPush(t *testing.T) {
+	for _, test := range []struct {
+		update   update.Update
+		expected string
+	}{
+		{
+			update:   update.Push("a", []interface{}{1, 2}),
+			expected: `{"$each": [{"$numberInt":"1"},{"$numberInt":"2"}]}`,
+		},
+		{
+			update:   update.Push("a", nil),
+			expected: `{"$each": []}`,
+		},
+		{
+			update:   update.Push("a", []interface{}{1}, update.Position(0), update.Slice(-5), update.SortValues(false)),
+			expected: `{"$each": [{"$numberInt":"1"}],"$position": {"$numberInt":"0"},"$slice": {"$numberInt":"-5"},"$sort": {"$numberInt":"-1"}}`,
+		},
+		{
+			update:   update.Push("a", []interface{}{}, update.SortByField("score", false), update.SortByField("name", true)),
+			expected: `{"$each": [],"$sort": {"score": {"$numberInt":"-1"},"name": {"$numberInt":"1"}}}`,
+		},
+	} {
+		raw, err := bson.Marshal(test.update)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		push, err := bson.Raw(raw).LookupErr("$push", "a")
+		if err != nil {
+			t.Fatalf("expected $push of the field, got %s", bson.Raw(raw))
+		}
+
+		if push.String() != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, push)
+		}
+	}
+}
+
+func TestPositional(t *testing.T) {
+	for path, expected := range map[string]string{
+		update.Positional("items"):                                     "items.$",
+		update.Positional("items", "quantity"):                         "items.$.quantity",
+		update.AllPositional("items", "tags"):                          "items.$[].tags",
+		update.FilteredPositional("items", "item", "quantity"):         "items.$[item].quantity",
+		update.FilteredPositional("a", "x", update.AllPositional("b")): "a.$[x].b.$[]",
+	} {
+		if path != expected {
+			t.Errorf("expected %s, got %s", expected, path)
+		}
+	}
+}
+
+func TestArrayFilters(t *testing.T) {
+	sku := filter.Equal("item.sku", "abc")
+	quantity := filter.GreaterThan("other.quantity", 0)
+
+	updates := []update.Update{
+		update.Set(update.FilteredPositional("items", "item", "quantity"), 0),
+		update.ArrayFilters(sku),
+		update.Set(update.FilteredPositional("others", "other", "shipped"), true),
+		update.ArrayFilters(quantity),
+	}
+
+	filters := update.ArrayFiltersOf(updates...)
+	if len(filters) != 2 || filters[0] != sku || filters[1] != quantity {
+		t.Fatalf("unexpected array filters %v", filters)
+	}
+
+	combined, err := update.Combine(updates...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*combined) != 1 {
+		t.Fatalf("expected only $set, got %v", *combined)
+	}
+}
+
+func TestPullInvalid(t *testing.T) {
+	for _, condition := range []filter.Filter{
+		filter.AND(filter.GreaterThan("", 1), filter.GreaterThan("", 2)),
+		filter.AND(filter.GreaterThan("", 1), filter.Equal("sku", "abc")),
+		filter.OR(filter.LessThan("", 1), filter.GreaterThan("", 5)),
+	} {
+		_, err := bson.Marshal(update.Pull("a", condition))
+		if err == nil || !strings.Contains(err.Error(), "update: Pull of a") {
+			t.Errorf("expected an error for %v, got %v", *condition, err)
+		}
+	}
+}
+
+func TestArrayUpdates(t *testing.T) {
+	for _, test := range []struct {
+		update   update.Update
+		path     []string
+		expected string
+	}{
+		{
+			update:   update.Pull("a", 3),
+			path:     []string{"$pull", "a"},
+			expected: `{"$numberInt":"3"}`,
+		},
+		{
+			update:   update.Pull("a", filter.GreaterThanOrEqual("", 6)),
+			path:     []string{"$pull", "a"},
+			expected: `{"$gte": {"$numberInt":"6"}}`,
+		},
+		{
+			update:   update.Pull("a", filter.AND(filter.GreaterThan("", 1), filter.LessThan("", 5))),
+			path:     []string{"$pull", "a"},
+			expected: `{"$gt": {"$numberInt":"1"},"$lt": {"$numberInt":"5"}}`,
+		},
+		{
+			update:   update.Pull("a", filter.AND(filter.GreaterThan("", 1), filter.NOT(filter.Equal("", 3)))),
+			path:     []string{"$pull", "a"},
+			expected: `{"$gt": {"$numberInt":"1"},"$not": {"$eq": {"$numberInt":"3"}}}`,
+		},
+		{
+			update:   update.Pull("a", filter.Equal("sku", "abc")),
+			path:     []string{"$pull", "a"},
+			expected: `{"sku": {"$eq": "abc"}}`,
+		},
+		{
+			update:   update.AddAllToSet("a", 1, 2),
+			path:     []string{"$addToSet", "a"},
+			expected: `{"$each": [{"$numberInt":"1"},{"$numberInt":"2"}]}`,
+		},
+		{
+			update:   update.AddAllToSet("a"),
+			path:     []string{"$addToSet", "a"},
+			expected: `{"$each": []}`,
+		},
+		{
+			update:   update.PushOne("a", "b"),
+			path:     []string{"$push", "a"},
+			expected: `"b"`,
+		},
+		{
+			update:   update.PushOne("a", "b", update.Position(0)),
+			path:     []string{"$push", "a"},
+			expected: `{"$each": ["b"],"$position": {"$numberInt":"0"}}`,
+		},
+		{
+			update:   update.Unset("a", "b"),
+			path:     []string{"$unset", "b"},
+			expected: `""`,
+		},
+	} {
+		raw, err := bson.Marshal(test.update)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		value, err := bson.Raw(raw).LookupErr(test.path...)
+		if err != nil {
+			t.Fatalf("%v: %v in %s", test.path, err, bson.Raw(raw))
+		}
+
+		if value.String() != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, value)
+		}
+	}
+}
@@ -0,0 +1,393 @@
+package wrap
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lucacasonato/wrap/filter"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ChangeOperation is the type of operation that caused a change event
+type ChangeOperation string
+
+const (
+	// InsertOperation means a document was added
+	InsertOperation ChangeOperation = "insert"
+	// UpdateOperation means a document was updated
+	UpdateOperation ChangeOperation = "update"
+	// ReplaceOperation means a document was set
+	ReplaceOperation ChangeOperation = "replace"
+	// DeleteOperation means a document was deleted
+	DeleteOperation ChangeOperation = "delete"
+	// DropOperation means a collection was deleted
+	DropOperation ChangeOperation = "drop"
+	// RenameOperation means a collection was renamed
+	RenameOperation ChangeOperation = "rename"
+	// DropDatabaseOperation means a database was deleted
+	DropDatabaseOperation ChangeOperation = "dropDatabase"
+	// InvalidateOperation means the change stream was invalidated, for example because the watched collection was deleted
+	InvalidateOperation ChangeOperation = "invalidate"
+)
+
+// ResumeToken identifies a change event. It can be stored and passed to
+// ResumeAfter or StartAfter to continue watching after that event
+type ResumeToken []byte
+
+// ChangeEvent is a change that happened on the server
+type ChangeEvent struct {
+	// Operation is the type of operation that caused the change
+	Operation ChangeOperation
+	// Document is the document that was changed. nil for operations that do not change a single document
+	Document *Document
+	// UpdatedFields are the fields that were changed by an update operation, by field name
+	UpdatedFields map[string]interface{}
+	// RemovedFields are the fields that were removed by an update operation
+	RemovedFields []string
+	// ResumeToken can be used to resume watching after this event
+	ResumeToken ResumeToken
+
+	fullDocument bson.RawValue
+}
+
+type changeEvent struct {
+	ID            bson.Raw      `bson:"_id"`
+	OperationType string        `bson:"operationType"`
+	FullDocument  bson.RawValue `bson:"fullDocument"`
+	NS            struct {
+		DB   string `bson:"db"`
+		Coll string `bson:"coll"`
+	} `bson:"ns"`
+	DocumentKey struct {
+		ID ID `bson:"_id"`
+	} `bson:"documentKey"`
+	UpdateDescription struct {
+		UpdatedFields map[string]interface{} `bson:"updatedFields"`
+		RemovedFields []string               `bson:"removedFields"`
+	} `bson:"updateDescription"`
+}
+
+// HasData returns true if the event contains the full document. This is the case for insert
+// and replace operations, and for update operations if the stream was opened with UpdateLookup
+func (e *ChangeEvent) HasData() bool {
+	return e.fullDocument.Type == bsontype.EmbeddedDocument
+}
+
+// Data decodes the full document and returns an interface. Returns ErrNotFound if the event does not contain the full document
+func (e *ChangeEvent) Data() (interface{}, error) {
+	var data interface{}
+
+	err := e.DataTo(&data)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// DataTo decodes the full document into an interface. Returns ErrNotFound if the event does not contain the full document
+func (e *ChangeEvent) DataTo(data interface{}) error {
+	if !e.HasData() {
+		return &Error{Kind: ErrNotFound, Err: mongo.ErrNoDocuments}
+	}
+
+	return e.fullDocument.Unmarshal(data)
+}
+
+// WatchOption configures a change stream
+type WatchOption func(opts *options.ChangeStreamOptions)
+
+// UpdateLookup includes the full document in events of update operations
+func UpdateLookup() WatchOption {
+	return func(opts *options.ChangeStreamOptions) {
+		opts.SetFullDocument(options.UpdateLookup)
+	}
+}
+
+// ResumeAfter starts watching after the event of the resume token
+func ResumeAfter(token ResumeToken) WatchOption {
+	return func(opts *options.ChangeStreamOptions) {
+		opts.SetResumeAfter(bson.Raw(token))
+	}
+}
+
+// StartAfter starts watching after the event of the resume token. Unlike ResumeAfter
+// this can also be used with the resume token of an invalidate event
+func StartAfter(token ResumeToken) WatchOption {
+	return func(opts *options.ChangeStreamOptions) {
+		opts.SetStartAfter(bson.Raw(token))
+	}
+}
+
+func changeStreamOptions(opts []WatchOption) *options.ChangeStreamOptions {
+	csOptions := options.ChangeStream()
+
+	for _, opt := range opts {
+		opt(csOptions)
+	}
+
+	return csOptions
+}
+
+// Watch all changes on all databases
+func (c *Client) Watch(opts ...WatchOption) (*ChangeIterator, error) {
+	ctx, cancel := c.ctx()
+	defer cancel()
+
+	stream, err := c.client.Watch(ctx, []*bson.M{}, changeStreamOptions(opts))
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	return &ChangeIterator{Client: c, stream: stream}, nil
+}
+
+// Watch all changes on the database
+func (db *Database) Watch(opts ...WatchOption) (*ChangeIterator, error) {
+	ctx, cancel := db.Client.ctx()
+	defer cancel()
+
+	stream, err := db.database.Watch(ctx, []*bson.M{}, changeStreamOptions(opts))
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	return &ChangeIterator{Client: db.Client, stream: stream}, nil
+}
+
+// Watch all changes on the collection
+func (c *Collection) Watch(opts ...WatchOption) (*ChangeIterator, error) {
+	return c.watch([]*bson.M{}, opts)
+}
+
+// Watch changes on the documents in the query. The filters of the query are
+// matched against the full document of the event, so events without a full
+// document (like deletes, or updates without UpdateLookup) never match. Only
+// queries made of Where filters can be watched. JavaScript and JSON schema
+// filters are not supported
+func (cq *CollectionQuery) Watch(opts ...WatchOption) (*ChangeIterator, error) {
+	if cq.err != nil {
+		return nil, cq.err
+	}
+
+	pipes := make([]*bson.M, len(cq.pipes))
+
+	for i, pipe := range cq.pipes {
+		match, ok := (*pipe)["$match"]
+		if !ok || len(*pipe) != 1 {
+			stages := []string{}
+
+			for stage := range *pipe {
+				stages = append(stages, stage)
+			}
+
+			return nil, fmt.Errorf("wrap: only Where filters can be used to watch changes, got %s", strings.Join(stages, ", "))
+		}
+
+		prefixed, err := prefixFields(match, "fullDocument.")
+		if err != nil {
+			return nil, err
+		}
+
+		pipes[i] = &bson.M{
+			"$match": prefixed,
+		}
+	}
+
+	return cq.Collection.watch(pipes, opts)
+}
+
+func (c *Collection) watch(pipes []*bson.M, opts []WatchOption) (*ChangeIterator, error) {
+	ctx, cancel := c.Database.Client.ctx()
+	defer cancel()
+
+	stream, err := c.collection.Watch(ctx, pipes, changeStreamOptions(opts))
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	return &ChangeIterator{Client: c.Database.Client, stream: stream}, nil
+}
+
+// prefixFields prefixes all field names in a filter
+func prefixFields(value interface{}, prefix string) (interface{}, error) {
+	switch v := value.(type) {
+	case filter.Filter:
+		return prefixFields(*v, prefix)
+	case *bson.M:
+		return prefixFields(*v, prefix)
+	case bson.M:
+		prefixed := bson.M{}
+
+		for key, val := range v {
+			switch key {
+			case "$and", "$or", "$nor":
+				p, err := prefixFields(val, prefix)
+				if err != nil {
+					return nil, err
+				}
+
+				prefixed[key] = p
+			case "$expr":
+				prefixed[key] = prefixPaths(val, prefix)
+			case "$where", "$jsonSchema", "$text":
+				return nil, fmt.Errorf("wrap: %s filters can not be used to watch changes", key)
+			default:
+				if strings.HasPrefix(key, "$") {
+					prefixed[key] = val
+				} else {
+					prefixed[prefix+key] = val
+				}
+			}
+		}
+
+		return prefixed, nil
+	case []filter.Filter:
+		prefixed := bson.A{}
+
+		for _, f := range v {
+			p, err := prefixFields(f, prefix)
+			if err != nil {
+				return nil, err
+			}
+
+			prefixed = append(prefixed, p)
+		}
+
+		return prefixed, nil
+	case bson.A:
+		prefixed := bson.A{}
+
+		for _, f := range v {
+			p, err := prefixFields(f, prefix)
+			if err != nil {
+				return nil, err
+			}
+
+			prefixed = append(prefixed, p)
+		}
+
+		return prefixed, nil
+	}
+
+	return value, nil
+}
+
+// prefixPaths prefixes all field paths like "$field" in an aggregation expression. Variables like "$$ROOT" are not changed
+func prefixPaths(expression interface{}, prefix string) interface{} {
+	switch v := expression.(type) {
+	case string:
+		if strings.HasPrefix(v, "$") && !strings.HasPrefix(v, "$$") {
+			return "$" + prefix + v[1:]
+		}
+	case *bson.M:
+		return prefixPaths(*v, prefix)
+	case bson.M:
+		prefixed := bson.M{}
+
+		for key, val := range v {
+			if key == "$literal" {
+				prefixed[key] = val
+				continue
+			}
+
+			prefixed[key] = prefixPaths(val, prefix)
+		}
+
+		return prefixed
+	case map[string]interface{}:
+		return prefixPaths(bson.M(v), prefix)
+	case bson.D:
+		prefixed := bson.D{}
+
+		for _, e := range v {
+			if e.Key != "$literal" {
+				e.Value = prefixPaths(e.Value, prefix)
+			}
+
+			prefixed = append(prefixed, e)
+		}
+
+		return prefixed
+	case bson.A:
+		prefixed := bson.A{}
+
+		for _, val := range v {
+			prefixed = append(prefixed, prefixPaths(val, prefix))
+		}
+
+		return prefixed
+	case []interface{}:
+		return prefixPaths(bson.A(v), prefix)
+	}
+
+	return expression
+}
+
+// Next waits for the next change event. Next does not time out, cancel the
+// context of the client to stop waiting
+func (i *ChangeIterator) Next() bool {
+	if !i.stream.Next(i.Client.context) {
+		return false
+	}
+
+	var event changeEvent
+
+	err := i.stream.Decode(&event)
+	if err != nil {
+		i.err = convertError(err)
+		return false
+	}
+
+	i.event = &ChangeEvent{
+		Operation:     ChangeOperation(event.OperationType),
+		UpdatedFields: event.UpdateDescription.UpdatedFields,
+		RemovedFields: event.UpdateDescription.RemovedFields,
+		ResumeToken:   ResumeToken(append([]byte{}, event.ID...)),
+		fullDocument: bson.RawValue{
+			Type:  event.FullDocument.Type,
+			Value: append([]byte{}, event.FullDocument.Value...),
+		},
+	}
+
+	if !event.DocumentKey.ID.IsZero() {
+		i.event.Document = i.Client.Database(event.NS.DB).Collection(event.NS.Coll).Document(event.DocumentKey.ID)
+	}
+
+	return true
+}
+
+// Event returns the current change event
+func (i *ChangeIterator) Event() *ChangeEvent {
+	return i.event
+}
+
+// ResumeToken returns the resume token of the last event
+func (i *ChangeIterator) ResumeToken() ResumeToken {
+	return ResumeToken(append([]byte{}, i.stream.ResumeToken()...))
+}
+
+// Err returns the error that stopped the iterator, if any
+func (i *ChangeIterator) Err() error {
+	if i.err != nil {
+		return i.err
+	}
+
+	return convertError(i.stream.Err())
+}
+
+// Close stops the iterator
+func (i *ChangeIterator) Close() error {
+	ctx, cancel := i.Client.ctx()
+	defer cancel()
+
+	err := i.stream.Close(ctx)
+	if err != nil {
+		return convertError(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package wrap_test
+
+import (
+	"testing"
+
+	"github.com/lucacasonato/wrap"
+	"github.com/lucacasonato/wrap/filter"
+	"github.com/lucacasonato/wrap/types"
+	"github.com/lucacasonato/wrap/update"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestWatch(t *testing.T) {
+	collection, err := createCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iterator, err := collection.Where(filter.Equal("name", "the red fish")).Watch(wrap.UpdateLookup())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iterator.Close()
+
+	_, err = collection.Add(map[string]interface{}{
+		"name": "the blue fish",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := collection.Add(map[string]interface{}{
+		"name": "the red fish",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !iterator.Next() {
+		t.Fatal(iterator.Err())
+	}
+
+	event := iterator.Event()
+	if event.Operation != wrap.InsertOperation || !event.Document.ID.Equal(doc.ID) {
+		t.Fatalf("unexpected event %+v", event)
+	}
+
+	token := iterator.ResumeToken()
+
+	_, err = doc.Update(false, update.Set("color", "red"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resumed, err := collection.Watch(wrap.ResumeAfter(token))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resumed.Close()
+
+	if !resumed.Next() {
+		t.Fatal(resumed.Err())
+	}
+
+	event = resumed.Event()
+	if event.Operation != wrap.UpdateOperation || event.UpdatedFields["color"] != "red" {
+		t.Fatalf("unexpected event %+v", event)
+	}
+
+	err = collection.Database.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatchExpression(t *testing.T) {
+	collection, err := createCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iterator, err := collection.Where(filter.Expression(bson.M{
+		"$gt": bson.A{"$length", "$width"},
+	})).Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iterator.Close()
+
+	_, err = collection.Add(map[string]interface{}{"length": 1, "width": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := collection.Add(map[string]interface{}{"length": 3, "width": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !iterator.Next() {
+		t.Fatal(iterator.Err())
+	}
+
+	if event := iterator.Event(); !event.Document.ID.Equal(doc.ID) {
+		t.Fatalf("unexpected event %+v", event)
+	}
+
+	err = collection.Database.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatchUnsupported(t *testing.T) {
+	collection, err := createCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []*wrap.CollectionQuery{
+		collection.All().Sort(wrap.Ascending("name")),
+		collection.All().Limit(1),
+		collection.Where(filter.Equal("name", "the red fish")).Skip(1),
+		collection.All().Join("name", "other", "name", "other"),
+		collection.Where(filter.JavascriptExpression("this.name == 'the red fish'")),
+		collection.Where(filter.OR(filter.Equal("a", 1), filter.Schema(filter.NewSchema(types.Object)))),
+	} {
+		_, err := query.Watch()
+		if err == nil {
+			t.Fatal("expected an error for an unsupported query")
+		}
+	}
+}
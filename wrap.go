@@ -2,6 +2,7 @@ package wrap
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -15,9 +16,8 @@ type Client struct {
 	timeout time.Duration
 }
 
-func (c *Client) ctx() context.Context {
-	ctx, _ := context.WithTimeout(c.context, c.timeout)
-	return ctx
+func (c *Client) ctx() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(c.context, c.timeout)
 }
 
 // Database is a database instance
@@ -36,7 +36,7 @@ type Collection struct {
 
 // Document is a document in a collection
 type Document struct {
-	ID         string
+	ID         ID
 	Collection *Collection
 }
 
@@ -50,6 +50,8 @@ type DocumentData struct {
 type CollectionQuery struct {
 	Collection *Collection
 	pipes      []*bson.M
+	// err is an error of a stage, returned when the query is run
+	err error
 }
 
 // Iterator to iterate over documents
@@ -58,15 +60,40 @@ type Iterator struct {
 	cursor     *mongo.Cursor
 }
 
+// ChangeIterator to iterate over change events
+type ChangeIterator struct {
+	Client *Client
+	stream *mongo.ChangeStream
+	event  *ChangeEvent
+	err    error
+}
+
 // BulkCollection is a collection which is used for bulk writing
 type BulkCollection struct {
 	ID         string
 	Collection *Collection
 	models     []mongo.WriteModel
+	insertIDs  []interface{}
+
+	queueLock sync.Mutex
+	ordered   bool
+	options   bulkOptions
+	size      int
+	offset    int
+	closed    bool
+	flushErr  error
+	workers   chan struct{}
+	wg        sync.WaitGroup
+
+	lock     sync.Mutex
+	result   *BulkResult
+	inserted []insertedID
+	err      error
+	stop     bool
 }
 
 // BulkDocument is a document which is used for bulk writing
 type BulkDocument struct {
-	ID         string
+	ID         ID
 	Collection *BulkCollection
 }